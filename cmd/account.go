@@ -0,0 +1,309 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/bariiss/flarecert/internal/acme"
+	"github.com/bariiss/flarecert/internal/config"
+	"github.com/bariiss/flarecert/internal/ui"
+
+	"github.com/spf13/cobra"
+)
+
+var accountCmd = &cobra.Command{
+	Use:   "account",
+	Short: "Manage ACME accounts",
+	Long: `Manage the ACME accounts stored in the certificate directory.
+
+Accounts are created automatically the first time a certificate is requested
+and reused afterwards. The account is selected by ACME_SERVER/ACME_CA (or --ca,
+--staging) and ACME_EMAIL (or --email).
+
+Examples:
+  # List stored accounts
+  flarecert account list
+
+  # Show the production account for ACME_EMAIL
+  flarecert account show
+
+  # Change the contact email of the staging account
+  flarecert account update-contact --staging --new-email ops@example.com
+
+  # Rotate the account key
+  flarecert account rollover-key`,
+}
+
+var accountListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List stored ACME accounts",
+	RunE:  runAccountListCommand,
+}
+
+var accountShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show ACME account details",
+	RunE:  runAccountShowCommand,
+}
+
+var accountUpdateContactCmd = &cobra.Command{
+	Use:   "update-contact",
+	Short: "Change the contact email of an ACME account",
+	Long: `Change the contact email of an ACME account.
+
+Accounts are stored under their contact email and orders use the account of
+ACME_EMAIL, so set ACME_EMAIL to the new address first and select the account
+with --email:
+
+  ACME_EMAIL=new@example.com flarecert account update-contact --email old@example.com --new-email new@example.com`,
+	RunE: runAccountUpdateContactCommand,
+}
+
+var accountRolloverKeyCmd = &cobra.Command{
+	Use:   "rollover-key",
+	Short: "Replace the ACME account key with a new one",
+	RunE:  runAccountRolloverKeyCommand,
+}
+
+var accountDeactivateCmd = &cobra.Command{
+	Use:   "deactivate",
+	Short: "Permanently deactivate an ACME account",
+	RunE:  runAccountDeactivateCommand,
+}
+
+var (
+	accountCertDir  string
+	accountEmail    string
+	accountStaging  bool
+	accountCA       string
+	accountNewEmail string
+	accountForce    bool
+)
+
+func init() {
+	rootCmd.AddCommand(accountCmd)
+	accountCmd.AddCommand(accountListCmd, accountShowCmd, accountUpdateContactCmd, accountRolloverKeyCmd, accountDeactivateCmd)
+
+	accountCmd.PersistentFlags().StringVar(&accountCertDir, "cert-dir", "./certs", "Directory containing certificates and accounts")
+	accountCmd.PersistentFlags().StringVar(&accountEmail, "email", "", "Account email (default: ACME_EMAIL)")
+	accountCmd.PersistentFlags().BoolVar(&accountStaging, "staging", false, "Use Let's Encrypt staging environment")
+	accountCmd.PersistentFlags().StringVar(&accountCA, "ca", "", "Certificate authority preset or ACME directory URL")
+	accountCmd.RegisterFlagCompletionFunc("ca", completeCAPresets)
+
+	accountUpdateContactCmd.Flags().StringVar(&accountNewEmail, "new-email", "", "New contact email (required)")
+	accountUpdateContactCmd.MarkFlagRequired("new-email")
+
+	accountDeactivateCmd.Flags().BoolVar(&accountForce, "force", false, "Deactivate without prompting")
+}
+
+func runAccountListCommand(cmd *cobra.Command, args []string) error {
+	store := acme.NewAccountStore(accountCertDir)
+
+	users, err := store.List()
+	if err != nil {
+		return err
+	}
+
+	if len(users) == 0 {
+		fmt.Println("No ACME accounts found in the certificate directory.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintln(w, "EMAIL\tSERVER\tSTATUS\tCREATED")
+	fmt.Fprintln(w, "-----\t------\t------\t-------")
+
+	for _, user := range users {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
+			user.Email,
+			user.Server,
+			accountStatus(user),
+			user.CreatedAt.Format("2006-01-02 15:04"),
+		)
+	}
+
+	return nil
+}
+
+func runAccountShowCommand(cmd *cobra.Command, args []string) error {
+	verbose, _ := cmd.Flags().GetBool("verbose")
+
+	store, user, err := loadAccount()
+	if err != nil {
+		return err
+	}
+
+	// Refresh from the server when possible, fall back to the stored registration
+	if user.Registration != nil && user.Registration.Body.Status != "deactivated" {
+		client, err := acme.NewAccountClient(store, user, verbose)
+		if err == nil {
+			if _, err := client.Query(); err != nil {
+				fmt.Printf("⚠️  Could not refresh account from server: %v\n\n", err)
+			}
+		}
+	}
+
+	thumbprint, err := user.KeyThumbprint()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("📧 Email:      %s\n", user.Email)
+	fmt.Printf("🌐 Server:     %s\n", user.Server)
+	fmt.Printf("📋 Status:     %s\n", accountStatus(user))
+	if user.Registration != nil {
+		fmt.Printf("🔗 URI:        %s\n", user.Registration.URI)
+		fmt.Printf("📇 Contact:    %s\n", strings.Join(user.Registration.Body.Contact, ", "))
+	}
+	fmt.Printf("🔑 Thumbprint: %s\n", thumbprint)
+	fmt.Printf("📅 Created:    %s\n", user.CreatedAt.Format("2006-01-02 15:04:05 MST"))
+	fmt.Printf("📁 Path:       %s\n", store.AccountDir(user.Server, user.Email))
+
+	return nil
+}
+
+func runAccountUpdateContactCommand(cmd *cobra.Command, args []string) error {
+	verbose, _ := cmd.Flags().GetBool("verbose")
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	client, user, err := loadAccountClient(verbose)
+	if err != nil {
+		return err
+	}
+
+	// The account is stored under its contact email, orders look it up by ACME_EMAIL and
+	// would silently register a new account once the account moved away from it
+	if user.Email == cfg.ACMEEmail && accountNewEmail != cfg.ACMEEmail {
+		return fmt.Errorf("ACME_EMAIL is %s, orders would no longer find the account after the change: set ACME_EMAIL=%s and run 'flarecert account update-contact --email %s --new-email %s'",
+			cfg.ACMEEmail, accountNewEmail, user.Email, accountNewEmail)
+	}
+
+	oldEmail := user.Email
+	if err := client.UpdateContact(accountNewEmail); err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ Account contact updated: %s -> %s\n", oldEmail, accountNewEmail)
+
+	return nil
+}
+
+func runAccountRolloverKeyCommand(cmd *cobra.Command, args []string) error {
+	verbose, _ := cmd.Flags().GetBool("verbose")
+
+	client, user, err := loadAccountClient(verbose)
+	if err != nil {
+		return err
+	}
+
+	if err := client.RolloverKey(); err != nil {
+		return err
+	}
+
+	thumbprint, err := user.KeyThumbprint()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ Account key rolled over for %s\n", user.Email)
+	fmt.Printf("🔑 New thumbprint: %s\n", thumbprint)
+
+	return nil
+}
+
+func runAccountDeactivateCommand(cmd *cobra.Command, args []string) error {
+	verbose, _ := cmd.Flags().GetBool("verbose")
+
+	client, user, err := loadAccountClient(verbose)
+	if err != nil {
+		return err
+	}
+
+	if !accountForce {
+		fmt.Printf("⚠️  Deactivating %s on %s cannot be undone.\n", user.Email, user.Server)
+		if !ui.AskUserConfirmation("Do you want to deactivate this account?") {
+			fmt.Println("Account deactivation cancelled.")
+			return nil
+		}
+	}
+
+	if err := client.Deactivate(); err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ Account %s deactivated\n", user.Registration.URI)
+
+	return nil
+}
+
+// loadAccount loads the stored account selected by the command flags and configuration
+func loadAccount() (*acme.AccountStore, *acme.User, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	if err := cfg.SelectCA(accountCA, accountStaging); err != nil {
+		return nil, nil, err
+	}
+	server := cfg.ACMEServer
+
+	email := cfg.ACMEEmail
+	if accountEmail != "" {
+		email = accountEmail
+	}
+
+	store := acme.NewAccountStore(accountCertDir)
+	user, err := store.Load(server, email)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, fmt.Errorf("no account found for %s on %s", email, server)
+		}
+		return nil, nil, err
+	}
+
+	return store, user, nil
+}
+
+// loadAccountClient loads the selected account and creates a client for it
+func loadAccountClient(verbose bool) (*acme.AccountClient, *acme.User, error) {
+	store, user, err := loadAccount()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if user.Registration != nil && user.Registration.Body.Status == "deactivated" {
+		return nil, nil, fmt.Errorf("account %s is deactivated", user.Registration.URI)
+	}
+
+	client, err := acme.NewAccountClient(store, user, verbose)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return client, user, nil
+}
+
+// accountStatus returns the display status of an account
+func accountStatus(user *acme.User) string {
+	if user.Registration == nil {
+		return "⚠️  Unregistered"
+	}
+
+	switch user.Registration.Body.Status {
+	case "valid", "":
+		return "✅ Valid"
+	case "deactivated":
+		return "❌ Deactivated"
+	default:
+		return "⚠️  " + user.Registration.Body.Status
+	}
+}
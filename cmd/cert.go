@@ -5,6 +5,7 @@ import (
 	"log"
 	"strings"
 
+	"github.com/bariiss/flarecert/internal/acme"
 	"github.com/bariiss/flarecert/internal/certificate"
 	"github.com/bariiss/flarecert/internal/k8s"
 	"github.com/bariiss/flarecert/internal/utils"
@@ -31,38 +32,91 @@ Examples:
   flarecert cert --domain example.com --k8s
 
   # Force renewal and create Kubernetes secret
-  flarecert cert --domain example.com --force --k8s`,
+  flarecert cert --domain example.com --force --k8s
+
+  # Use ZeroSSL (requires ACME_EAB_KID and ACME_EAB_HMAC)
+  flarecert cert --domain example.com --ca zerossl
+
+  # Issue for an externally generated key (HSM, appliance); domains come from the CSR
+  flarecert cert --csr request.csr
+
+  # Keep the same private key across renewals (for HPKP-style or DANE TLSA pinning)
+  flarecert cert --domain example.com --reuse-key --max-key-age 180
+
+  # Request the alternate chain leading to a specific root
+  flarecert cert --domain example.com --preferred-chain "ISRG Root X1"
+
+  # Issue RSA and ECDSA certificates side by side (current/rsa/ and current/ecdsa/)
+  flarecert cert --domain example.com --key-type rsa2048,ec256
+
+  # Request a six-day short-lived certificate
+  flarecert cert --domain example.com --profile shortlived
+
+  # Use a specific Cloudflare zone instead of detecting it (no prompt in CI)
+  flarecert cert --domain app.internal.example.com --zone example.com
+
+  # Require OCSP stapling (TLS Feature extension)
+  flarecert cert --domain example.com --must-staple`,
 	RunE: runCertCommand,
 }
 
 var (
-	domains       []string
-	certDir       string
-	staging       bool
-	keyType       string
-	forceRenew    bool
-	createK8sYaml bool
+	domains        []string
+	certDir        string
+	staging        bool
+	caName         string
+	keyType        string
+	forceRenew     bool
+	createK8sYaml  bool
+	csrPath        string
+	reuseKey       bool
+	maxKeyAge      int
+	preferredChain string
+	profile        string
+	mustStaple     bool
+	zone           string
+	skipPreflight  bool
 )
 
 func init() {
 	rootCmd.AddCommand(certCmd)
 
-	certCmd.Flags().StringSliceVarP(&domains, "domain", "d", []string{}, "Domain name(s) for the certificate (required)")
+	certCmd.Flags().StringSliceVarP(&domains, "domain", "d", []string{}, "Domain name(s) for the certificate (required unless --csr is used)")
 	certCmd.Flags().StringVar(&certDir, "cert-dir", "./certs", "Directory to store certificates")
 	certCmd.Flags().BoolVar(&staging, "staging", false, "Use Let's Encrypt staging environment")
-	certCmd.Flags().StringVar(&keyType, "key-type", "rsa2048", "Key type: rsa2048, rsa4096, ec256, ec384")
+	certCmd.Flags().StringVar(&caName, "ca", "", "Certificate authority preset or ACME directory URL (letsencrypt, letsencrypt-staging, zerossl, google, buypass)")
+	certCmd.Flags().StringVar(&keyType, "key-type", "rsa2048", "Key type: rsa2048, rsa3072, rsa4096, rsa8192, ec256, ec384, or one RSA and one ECDSA type separated by a comma")
 	certCmd.Flags().BoolVar(&forceRenew, "force", false, "Force renewal even if certificate is valid")
 	certCmd.Flags().BoolVar(&createK8sYaml, "k8s", false, "Generate Kubernetes Secret YAML file")
+	certCmd.Flags().StringVar(&csrPath, "csr", "", "Issue the certificate for an existing CSR (PEM or DER) instead of generating a key")
+	certCmd.Flags().StringVar(&preferredChain, "preferred-chain", "", "Common name of the root the certificate chain should lead to, if the CA offers it (remembered for renewals)")
+	certCmd.Flags().StringVar(&profile, "profile", "", "ACME certificate profile to request, e.g. classic, tlsserver, shortlived (remembered for renewals)")
+	certCmd.Flags().BoolVar(&mustStaple, "must-staple", false, "Request the OCSP Must-Staple (TLS Feature) extension (remembered for renewals)")
+	certCmd.Flags().StringVar(&zone, "zone", "", "Cloudflare zone name or ID for the challenge records, skips zone detection (remembered for renewals, --zone \"\" forgets it)")
+	certCmd.Flags().BoolVar(&skipPreflight, "skip-preflight", false, "Skip the token, zone, CAA and ACME server checks before ordering")
+	certCmd.Flags().BoolVar(&reuseKey, "reuse-key", false, "Keep the existing private key when renewing (remembered for future renewals)")
+	certCmd.Flags().IntVar(&maxKeyAge, "max-key-age", certificate.DefaultMaxKeyAgeDays, "Generate a new key once a reused key is this many days old (0 = no limit)")
 
 	// Register completion for domain flag
 	certCmd.RegisterFlagCompletionFunc("domain", GetDomainCompletions)
 
+	// Register completion for ca flag
+	certCmd.RegisterFlagCompletionFunc("ca", completeCAPresets)
+
+	// Register completion for profile flag
+	certCmd.RegisterFlagCompletionFunc("profile", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return acme.KnownProfiles, cobra.ShellCompDirectiveNoFileComp
+	})
+
 	// Register completion for key-type flag
 	certCmd.RegisterFlagCompletionFunc("key-type", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-		return []string{"rsa2048", "rsa4096", "ec256", "ec384"}, cobra.ShellCompDirectiveNoFileComp
+		return acme.KeyTypeNames, cobra.ShellCompDirectiveNoFileComp
 	})
 
-	certCmd.MarkFlagRequired("domain")
+	certCmd.MarkFlagsMutuallyExclusive("domain", "csr")
+	certCmd.MarkFlagsMutuallyExclusive("csr", "reuse-key")
+	certCmd.MarkFlagsMutuallyExclusive("csr", "must-staple")
+	certCmd.MarkFlagsOneRequired("domain", "csr")
 }
 
 func runCertCommand(cmd *cobra.Command, args []string) error {
@@ -73,20 +127,68 @@ func runCertCommand(cmd *cobra.Command, args []string) error {
 	}
 
 	// Create certificate manager
-	manager, err := certificate.NewManager(certDir, keyType, staging, forceRenew, verbose)
+	manager, err := certificate.NewManager(cmd.Context(), certDir, keyType, caName, staging, forceRenew, verbose)
 	if err != nil {
 		return fmt.Errorf("failed to create certificate manager: %w", err)
 	}
 
+	// Only override the persisted chain, profile and must-staple settings when asked to
+	if cmd.Flags().Changed("preferred-chain") {
+		manager.SetPreferredChain(preferredChain)
+	}
+	if cmd.Flags().Changed("profile") {
+		manager.SetProfile(profile)
+	}
+	if cmd.Flags().Changed("must-staple") {
+		manager.SetMustStaple(mustStaple)
+	}
+	if cmd.Flags().Changed("zone") {
+		manager.SetZone(zone)
+	}
+
+	// Only override the persisted key reuse settings when asked to
+	if cmd.Flags().Changed("reuse-key") {
+		manager.SetReuseKey(reuseKey)
+	}
+	if cmd.Flags().Changed("max-key-age") {
+		manager.SetMaxKeyAge(maxKeyAge)
+	}
+
+	// Catch token, zone and ACME server problems before the order is placed
+	if !skipPreflight {
+		preflightDomains := domains
+		if csrPath != "" {
+			csr, err := acme.LoadCSR(csrPath)
+			if err != nil {
+				return err
+			}
+			preflightDomains = acme.CSRDomains(csr)
+		}
+
+		if err := manager.Preflight(preflightDomains); err != nil {
+			return fmt.Errorf("%w (run 'flarecert doctor' for details, or use --skip-preflight)", err)
+		}
+	}
+
 	// Generate certificate
+	if csrPath != "" {
+		csrDomains, err := manager.GenerateCertificateFromCSR(csrPath)
+		if err != nil {
+			return err
+		}
+
+		if createK8sYaml {
+			log.Printf("Warning: skipping Kubernetes secret YAML for %s: private key is not available for CSR-based certificates", strings.Join(csrDomains, ", "))
+		}
+		return nil
+	}
+
 	if err := manager.GenerateCertificate(domains); err != nil {
 		return err
 	}
 
 	// Create Kubernetes Secret YAML if requested
 	if createK8sYaml {
-		paths := utils.GetCertificatePathsForDomains(certDir, domains)
-
 		// Determine primary domain (prefer wildcard for naming)
 		primaryDomain := domains[0]
 		for _, domain := range domains {
@@ -96,9 +198,21 @@ func runCertCommand(cmd *cobra.Command, args []string) error {
 			}
 		}
 
+		// Dual RSA and ECDSA certificates get one secret per variant
+		variants := []string{""}
+		if keyTypes := certificate.SplitKeyTypes(keyType); len(keyTypes) > 1 {
+			variants = variants[:0]
+			for _, kt := range keyTypes {
+				variants = append(variants, utils.KeyVariant(kt))
+			}
+		}
+
 		secretGen := k8s.NewSecretGenerator(verbose)
-		if err := secretGen.CreateSecret(&paths, primaryDomain, domains); err != nil {
-			log.Printf("Warning: failed to create Kubernetes secret YAML: %v", err)
+		for _, variant := range variants {
+			paths := utils.GetCertificatePathsForVariant(certDir, domains, variant)
+			if err := secretGen.CreateSecret(&paths, primaryDomain, domains); err != nil {
+				log.Printf("Warning: failed to create Kubernetes secret YAML: %v", err)
+			}
 		}
 	}
 
@@ -3,6 +3,7 @@ package cmd
 import (
 	"os"
 	"strings"
+	"time"
 
 	"github.com/bariiss/flarecert/internal/config"
 	"github.com/bariiss/flarecert/internal/dns"
@@ -76,14 +77,25 @@ func GetDomainCompletions(cmd *cobra.Command, args []string, toComplete string)
 		return nil, cobra.ShellCompDirectiveNoFileComp
 	}
 
-	provider, err := dns.NewCloudflareProvider(cfg.CloudflareAPIToken, cfg.CloudflareEmail, cfg.DNSTimeout, false)
-	if err != nil {
-		return nil, cobra.ShellCompDirectiveNoFileComp
+	// The zone cache lives in the certificate directory of the command being completed
+	certDir := cfg.CertDir
+	if dir, err := cmd.Flags().GetString("cert-dir"); err == nil {
+		certDir = dir
 	}
 
-	zones, err := provider.ListZones()
-	if err != nil {
-		return nil, cobra.ShellCompDirectiveNoFileComp
+	// Serve completions from the zone cache, only calling Cloudflare when it is stale
+	cacheTTL := time.Duration(cfg.ZoneCacheTTL) * time.Second
+	zones, ok := dns.LoadCachedZones(dns.ZoneCachePath(certDir), cacheTTL, dns.AccountsFingerprint(cfg.CloudflareCredentials(), cfg.ZoneAccounts))
+	if !ok {
+		provider, err := dns.NewProviderFromConfig(cmd.Context(), cfg, certDir, false)
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+
+		zones, err = provider.ListZones()
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
 	}
 
 	var suggestions []string
@@ -109,6 +121,11 @@ func GetDomainCompletions(cmd *cobra.Command, args []string, toComplete string)
 	return suggestions, cobra.ShellCompDirectiveNoFileComp
 }
 
+// completeCAPresets returns the names of the built-in CA presets
+func completeCAPresets(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return config.CAPresetNames(), cobra.ShellCompDirectiveNoFileComp
+}
+
 func init() {
 	rootCmd.AddCommand(completionCmd)
 }
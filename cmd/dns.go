@@ -0,0 +1,315 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bariiss/flarecert/internal/acme"
+	"github.com/bariiss/flarecert/internal/config"
+	"github.com/bariiss/flarecert/internal/dns"
+	"github.com/bariiss/flarecert/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var dnsCmd = &cobra.Command{
+	Use:   "dns",
+	Short: "Manage the DNS records used for ACME challenges",
+}
+
+var dnsCleanupCmd = &cobra.Command{
+	Use:   "cleanup",
+	Short: "Delete orphaned _acme-challenge TXT records",
+	Long: `Find and delete challenge TXT records left behind in your Cloudflare zones,
+e.g. when flarecert was killed during an order or a record could not be deleted.
+
+Records created by flarecert carry the comment "flarecert acme-challenge" and are
+considered orphaned once they are older than --older-than. Use --all to also sweep
+untagged _acme-challenge TXT records, such as ones created by older versions.
+
+Examples:
+  # Show what would be deleted
+  flarecert dns cleanup --dry-run
+
+  # Delete tagged challenge records older than 6 hours without asking
+  flarecert dns cleanup --older-than 6h --force
+
+  # Include untagged _acme-challenge records
+  flarecert dns cleanup --all`,
+	RunE: runDNSCleanupCommand,
+}
+
+var dnsCAACmd = &cobra.Command{
+	Use:   "caa",
+	Short: "Authorize the configured CA in CAA records",
+	Long: `Create or update CAA records in Cloudflare so the configured CA may issue for
+the given names. An existing issue record of the CA is updated, records of other
+CAs are left alone. Wildcard names, or --wildcard, also set an issuewild record.
+
+A name without CAA records of its own inherits the records of its closest parent
+(RFC 8659), which stop applying once records are written at the name, so they are
+copied along. The first issuewild record likewise keeps the CAs of the issue records
+allowed to issue wildcards. When no record restricts issuance yet, the new records
+allow only the configured CA, which is shown as a warning before writing.
+
+The record is pinned to the ACME account registered with the CA (RFC 8657
+accounturi) once one exists, so other accounts at the same CA cannot issue.
+
+Examples:
+  # Show the records that would be written
+  flarecert dns caa --domain example.com --dry-run
+
+  # Authorize ZeroSSL for example.com and its wildcard
+  flarecert dns caa --domain example.com --wildcard --ca zerossl
+
+  # Authorize the CA without pinning the account
+  flarecert dns caa --domain example.com --no-account-uri --force`,
+	RunE: runDNSCAACommand,
+}
+
+var (
+	dnsCAADomains      []string
+	dnsCAAWildcard     bool
+	dnsCAANoAccountURI bool
+	dnsCAACA           string
+	dnsCAAStaging      bool
+	dnsCAACertDir      string
+	dnsCAADryRun       bool
+	dnsCAAForce        bool
+)
+
+var (
+	dnsCleanupOlderThan time.Duration
+	dnsCleanupDryRun    bool
+	dnsCleanupAll       bool
+	dnsCleanupForce     bool
+	dnsCleanupCertDir   string
+)
+
+func init() {
+	rootCmd.AddCommand(dnsCmd)
+	dnsCmd.AddCommand(dnsCleanupCmd)
+	dnsCmd.AddCommand(dnsCAACmd)
+
+	dnsCleanupCmd.Flags().DurationVar(&dnsCleanupOlderThan, "older-than", time.Hour, "Only delete records created longer ago than this")
+	dnsCleanupCmd.Flags().BoolVar(&dnsCleanupDryRun, "dry-run", false, "List orphaned records without deleting them")
+	dnsCleanupCmd.Flags().BoolVar(&dnsCleanupAll, "all", false, "Include _acme-challenge TXT records not tagged by flarecert")
+	dnsCleanupCmd.Flags().BoolVar(&dnsCleanupForce, "force", false, "Delete without prompting for confirmation")
+	dnsCleanupCmd.Flags().StringVar(&dnsCleanupCertDir, "cert-dir", "./certs", "Directory containing certificates and the zone cache")
+
+	dnsCAACmd.Flags().StringSliceVarP(&dnsCAADomains, "domain", "d", []string{}, "Name to authorize the CA for, a wildcard also sets issuewild")
+	dnsCAACmd.Flags().BoolVar(&dnsCAAWildcard, "wildcard", false, "Also authorize wildcard certificates (issuewild)")
+	dnsCAACmd.Flags().BoolVar(&dnsCAANoAccountURI, "no-account-uri", false, "Do not pin the records to the ACME account")
+	dnsCAACmd.Flags().StringVar(&dnsCAACA, "ca", "", "Certificate authority preset or ACME directory URL")
+	dnsCAACmd.Flags().BoolVar(&dnsCAAStaging, "staging", false, "Use Let's Encrypt staging environment")
+	dnsCAACmd.Flags().StringVar(&dnsCAACertDir, "cert-dir", "./certs", "Directory containing the ACME accounts and the zone cache")
+	dnsCAACmd.Flags().BoolVar(&dnsCAADryRun, "dry-run", false, "Show the changes without writing them")
+	dnsCAACmd.Flags().BoolVar(&dnsCAAForce, "force", false, "Write without prompting for confirmation")
+
+	dnsCAACmd.RegisterFlagCompletionFunc("domain", GetDomainCompletions)
+	dnsCAACmd.RegisterFlagCompletionFunc("ca", completeCAPresets)
+	dnsCAACmd.MarkFlagRequired("domain")
+}
+
+func runDNSCleanupCommand(cmd *cobra.Command, args []string) error {
+	verbose, _ := cmd.Flags().GetBool("verbose")
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	provider, err := dns.NewProviderFromConfig(cmd.Context(), cfg, dnsCleanupCertDir, verbose)
+	if err != nil {
+		return fmt.Errorf("failed to create Cloudflare provider: %w", err)
+	}
+	defer provider.LogRetrySummary()
+
+	if verbose {
+		log.Printf("🔍 Searching for challenge records older than %s...", dnsCleanupOlderThan)
+	}
+
+	records, err := provider.FindStaleRecords(dnsCleanupOlderThan, dnsCleanupAll)
+	if err != nil {
+		return err
+	}
+
+	if len(records) == 0 {
+		fmt.Println("✅ No orphaned challenge records found")
+		return nil
+	}
+
+	fmt.Printf("🧹 Found %d orphaned challenge record(s):\n\n", len(records))
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "ZONE\tNAME\tCREATED\tTAGGED\tRECORD ID")
+	fmt.Fprintln(w, "----\t----\t-------\t------\t---------")
+	for _, record := range records {
+		tagged := "no"
+		if record.Tagged {
+			tagged = "yes"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", record.ZoneName, record.Name, record.CreatedOn.Local().Format("2006-01-02 15:04"), tagged, record.RecordID)
+	}
+	w.Flush()
+	fmt.Println()
+
+	if dnsCleanupDryRun {
+		fmt.Println("💡 Dry run, no records were deleted")
+		return nil
+	}
+
+	if !dnsCleanupForce && !ui.AskUserConfirmation(fmt.Sprintf("Do you want to delete these %d record(s)", len(records))) {
+		fmt.Println("Cleanup cancelled.")
+		return nil
+	}
+
+	deleted := 0
+	for _, record := range records {
+		if cmd.Context().Err() != nil {
+			break
+		}
+		if err := provider.DeleteRecord(record.ZoneID, record.RecordID); err != nil {
+			log.Printf("❌ Failed to delete %s: %v", record.Name, err)
+			continue
+		}
+		deleted++
+		if verbose {
+			log.Printf("Deleted %s (%s)", record.Name, record.RecordID)
+		}
+	}
+
+	fmt.Printf("✅ Deleted %d of %d record(s)\n", deleted, len(records))
+	if deleted < len(records) {
+		return fmt.Errorf("failed to delete %d record(s)", len(records)-deleted)
+	}
+
+	return nil
+}
+
+func runDNSCAACommand(cmd *cobra.Command, args []string) error {
+	verbose, _ := cmd.Flags().GetBool("verbose")
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	if err := cfg.SelectCA(dnsCAACA, dnsCAAStaging); err != nil {
+		return err
+	}
+
+	identities := cfg.CAAIdentitiesFor(cfg.ACMEServer)
+	if len(identities) == 0 {
+		return fmt.Errorf("CAA identity of %s is unknown, set ACME_CAA_IDENTITY to the issuer domain the CA documents", cfg.ACMEServer)
+	}
+	issuer := identities[0]
+
+	// Pin the records to the account orders are placed with
+	value := issuer
+	if !dnsCAANoAccountURI {
+		if uri := acme.StoredAccountURI(dnsCAACertDir, cfg.ACMEServer, cfg.ACMEEmail); uri != "" {
+			value += "; accounturi=" + uri
+		} else {
+			fmt.Printf("⚠️  No ACME account registered with %s yet, authorizing the CA without accounturi\n", cfg.ACMEServer)
+		}
+	}
+
+	provider, err := dns.NewProviderFromConfig(cmd.Context(), cfg, dnsCAACertDir, verbose)
+	if err != nil {
+		return fmt.Errorf("failed to create Cloudflare provider: %w", err)
+	}
+	defer provider.LogRetrySummary()
+
+	// example.com and *.example.com share the records at example.com
+	var names []string
+	nameTags := make(map[string][]string)
+	for _, domain := range dnsCAADomains {
+		name := strings.ToLower(strings.TrimPrefix(domain, "*."))
+		if _, ok := nameTags[name]; !ok {
+			names = append(names, name)
+			nameTags[name] = []string{"issue"}
+		}
+
+		wildcard := dnsCAAWildcard || strings.HasPrefix(domain, "*.")
+		if wildcard && len(nameTags[name]) == 1 {
+			nameTags[name] = append(nameTags[name], "issuewild")
+		}
+	}
+
+	var changes []dns.CAAChange
+	var warnings []string
+	for _, name := range names {
+		// Records written at the name replace the set it inherits from a parent today
+		inherited, err := dns.LookupCAA(cmd.Context(), name, cfg.DNSResolvers)
+		if err != nil {
+			return err
+		}
+
+		planned, notes, err := provider.PlanCAA(name, nameTags[name], issuer, value, inherited)
+		if err != nil {
+			return err
+		}
+		changes = append(changes, planned...)
+		warnings = append(warnings, notes...)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "NAME\tTAG\tVALUE\tACTION")
+	fmt.Fprintln(w, "----\t---\t-----\t------")
+	pending := 0
+	for _, change := range changes {
+		action := change.Action
+		if change.Action == "update" {
+			action = fmt.Sprintf("update (was %q)", change.Previous)
+		}
+		if change.Reason != "" {
+			action = fmt.Sprintf("%s (%s)", action, change.Reason)
+		}
+		if change.Action != "none" {
+			pending++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%q\t%s\n", change.Name, change.Tag, change.Value, action)
+	}
+	w.Flush()
+	fmt.Println()
+
+	if pending == 0 {
+		fmt.Printf("✅ CAA records already authorize %s\n", issuer)
+		return nil
+	}
+
+	for _, warning := range warnings {
+		fmt.Printf("⚠️  %s\n", warning)
+	}
+	if len(warnings) > 0 {
+		fmt.Println()
+	}
+
+	if dnsCAADryRun {
+		fmt.Println("💡 Dry run, no records were written")
+		return nil
+	}
+
+	if !dnsCAAForce && !ui.AskUserConfirmation(fmt.Sprintf("Do you want to write these %d CAA record(s)", pending)) {
+		fmt.Println("CAA update cancelled.")
+		return nil
+	}
+
+	for _, change := range changes {
+		if change.Action == "none" {
+			continue
+		}
+		if err := provider.ApplyCAA(change); err != nil {
+			return err
+		}
+		if verbose {
+			log.Printf("Wrote CAA %s record at %s (%s)", change.Tag, change.Name, change.Action)
+		}
+	}
+
+	fmt.Printf("✅ Wrote %d CAA record(s) authorizing %s\n", pending, issuer)
+	return nil
+}
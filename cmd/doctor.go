@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/bariiss/flarecert/internal/config"
+	"github.com/bariiss/flarecert/internal/preflight"
+	"github.com/spf13/cobra"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the configuration, Cloudflare token and ACME server",
+	Long: `Check everything a certificate order depends on and print a fix for each problem:
+
+  - the certificate directory is writable
+  - the ACME directory of each configured CA is reachable and the clock is in sync
+  - the Cloudflare API token is active and not about to expire
+  - which zones the token can read and edit
+  - with --domain, that CAA records allow the configured CAs to issue
+
+The same checks run automatically for the zones involved before 'cert' and 'renew'
+place an order (skip them with --skip-preflight).
+
+Examples:
+  # Check all zones
+  flarecert doctor
+
+  # Check only the zones of these domains, proving DNS:Edit with a probe record
+  flarecert doctor --domain example.com --domain "*.example.org" --write-test`,
+	RunE: runDoctorCommand,
+}
+
+var (
+	doctorDomains   []string
+	doctorCertDir   string
+	doctorCA        string
+	doctorStaging   bool
+	doctorWriteTest bool
+)
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+
+	doctorCmd.Flags().StringSliceVarP(&doctorDomains, "domain", "d", []string{}, "Only check the zones of these domains")
+	doctorCmd.Flags().StringVar(&doctorCertDir, "cert-dir", "./certs", "Directory to store certificates")
+	doctorCmd.Flags().StringVar(&doctorCA, "ca", "", "Certificate authority preset or ACME directory URL")
+	doctorCmd.Flags().BoolVar(&doctorStaging, "staging", false, "Use Let's Encrypt staging environment")
+	doctorCmd.Flags().BoolVar(&doctorWriteTest, "write-test", false, "Verify DNS:Edit by creating and deleting a probe TXT record in each zone")
+
+	doctorCmd.RegisterFlagCompletionFunc("domain", GetDomainCompletions)
+	doctorCmd.RegisterFlagCompletionFunc("ca", completeCAPresets)
+}
+
+func runDoctorCommand(cmd *cobra.Command, args []string) error {
+	verbose, _ := cmd.Flags().GetBool("verbose")
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Printf("❌ Configuration: %v\n", err)
+		fmt.Println("   💡 Copy .env.example to .env and fill in the required values")
+		return fmt.Errorf("doctor found problems")
+	}
+
+	if err := cfg.SelectCA(doctorCA, doctorStaging); err != nil {
+		return err
+	}
+	cfg.CertDir = doctorCertDir
+
+	fmt.Println("🩺 Checking FlareCert setup...")
+	fmt.Println()
+
+	report := preflight.Run(cmd.Context(), preflight.Options{
+		Config:    cfg,
+		Domains:   doctorDomains,
+		WriteTest: doctorWriteTest,
+		CAA:       len(doctorDomains) > 0,
+		Verbose:   verbose,
+	})
+	report.Print(false)
+	fmt.Println()
+
+	if report.Failed() {
+		return fmt.Errorf("doctor found problems")
+	}
+
+	fmt.Println("✅ Everything looks good")
+	return nil
+}
@@ -81,11 +81,10 @@ func runExportCommand(cmd *cobra.Command, args []string) error {
 			return fmt.Errorf("failed to find certificates: %w", err)
 		}
 	} else {
-		cert, err := findCertificateByDomain(exportCertDir, exportDomain, verbose)
+		certsToExport, err = findCertificateByDomain(exportCertDir, exportDomain, verbose)
 		if err != nil {
 			return fmt.Errorf("failed to find certificate for domain %s: %w", exportDomain, err)
 		}
-		certsToExport = []CertificateExportInfo{*cert}
 	}
 
 	if len(certsToExport) == 0 {
@@ -98,7 +97,12 @@ func runExportCommand(cmd *cobra.Command, args []string) error {
 	successCount := 0
 
 	for _, cert := range certsToExport {
-		fmt.Printf("📝 Exporting certificate: %s\n", cert.DirectoryName)
+		if !cert.HasPrivateKey {
+			fmt.Printf("⏭️  Skipping %s: no private key stored (certificate was issued from a CSR)\n", utils.VariantLabel(cert.DirectoryName, cert.Variant))
+			continue
+		}
+
+		fmt.Printf("📝 Exporting certificate: %s\n", utils.VariantLabel(cert.DirectoryName, cert.Variant))
 
 		// Determine output directory
 		outputDir := exportOutputDir
@@ -117,6 +121,7 @@ func runExportCommand(cmd *cobra.Command, args []string) error {
 			ChainFile:     cert.ChainFile,
 			FullchainFile: cert.FullchainFile,
 			InfoFile:      cert.InfoFile,
+			Variant:       cert.Variant,
 		}
 
 		// Determine primary domain (prefer wildcard)
@@ -129,7 +134,7 @@ func runExportCommand(cmd *cobra.Command, args []string) error {
 		}
 
 		if err := secretGen.CreateSecret(&outputPaths, primaryDomain, cert.Domains); err != nil {
-			log.Printf("❌ Failed to export %s: %v", cert.DirectoryName, err)
+			log.Printf("❌ Failed to export %s: %v", utils.VariantLabel(cert.DirectoryName, cert.Variant), err)
 			continue
 		}
 
@@ -144,6 +149,7 @@ func runExportCommand(cmd *cobra.Command, args []string) error {
 
 type CertificateExportInfo struct {
 	DirectoryName  string
+	Variant        string
 	CertificateDir string
 	Domains        []string
 	ExpiresAt      string
@@ -152,6 +158,7 @@ type CertificateExportInfo struct {
 	ChainFile      string
 	FullchainFile  string
 	InfoFile       string
+	HasPrivateKey  bool
 }
 
 func findAllCertificates(certDir string, verbose bool) ([]CertificateExportInfo, error) {
@@ -170,21 +177,13 @@ func findAllCertificates(certDir string, verbose bool) ([]CertificateExportInfo,
 			continue
 		}
 
-		cert, err := buildCertificateExportInfo(certDir, entry.Name(), verbose)
-		if err != nil {
-			if verbose {
-				log.Printf("Skipping %s: %v", entry.Name(), err)
-			}
-			continue
-		}
-
-		certificates = append(certificates, *cert)
+		certificates = append(certificates, buildCertificateExportInfos(certDir, entry.Name(), verbose)...)
 	}
 
 	return certificates, nil
 }
 
-func findCertificateByDomain(certDir, domain string, verbose bool) (*CertificateExportInfo, error) {
+func findCertificateByDomain(certDir, domain string, verbose bool) ([]CertificateExportInfo, error) {
 	// Try to find certificate directory by domain
 	entries, err := os.ReadDir(certDir)
 	if err != nil {
@@ -196,15 +195,15 @@ func findCertificateByDomain(certDir, domain string, verbose bool) (*Certificate
 			continue
 		}
 
-		cert, err := buildCertificateExportInfo(certDir, entry.Name(), verbose)
-		if err != nil {
+		certs := buildCertificateExportInfos(certDir, entry.Name(), false)
+		if len(certs) == 0 {
 			continue
 		}
 
-		// Check if any of the certificate domains match
-		for _, certDomain := range cert.Domains {
+		// Check if any of the certificate domains match, RSA and ECDSA variants share their domains
+		for _, certDomain := range certs[0].Domains {
 			if certDomain == domain {
-				return cert, nil
+				return certs, nil
 			}
 		}
 	}
@@ -212,22 +211,45 @@ func findCertificateByDomain(certDir, domain string, verbose bool) (*Certificate
 	return nil, fmt.Errorf("certificate not found for domain: %s", domain)
 }
 
-func buildCertificateExportInfo(certDir, dirName string, verbose bool) (*CertificateExportInfo, error) {
-	certPath := filepath.Join(certDir, dirName, "current", "cert.pem")
-	keyPath := filepath.Join(certDir, dirName, "current", "privkey.pem")
-	chainPath := filepath.Join(certDir, dirName, "current", "chain.pem")
-	fullchainPath := filepath.Join(certDir, dirName, "current", "fullchain.pem")
-	infoPath := filepath.Join(certDir, dirName, "current", "cert.json")
+// buildCertificateExportInfos returns the certificates in a directory, one per RSA/ECDSA variant
+func buildCertificateExportInfos(certDir, dirName string, verbose bool) []CertificateExportInfo {
+	var certificates []CertificateExportInfo
+
+	variants := utils.FindCertificateVariants(filepath.Join(certDir, dirName))
+	if len(variants) == 0 && verbose {
+		log.Printf("Skipping %s: no certificate found", dirName)
+	}
+
+	for _, variant := range variants {
+		cert, err := buildCertificateExportInfo(certDir, dirName, variant, verbose)
+		if err != nil {
+			if verbose {
+				log.Printf("Skipping %s: %v", utils.VariantLabel(dirName, variant), err)
+			}
+			continue
+		}
+		certificates = append(certificates, *cert)
+	}
+
+	return certificates
+}
+
+func buildCertificateExportInfo(certDir, dirName, variant string, verbose bool) (*CertificateExportInfo, error) {
+	paths := utils.GetCertificatePathsForDir(filepath.Join(certDir, dirName), variant)
+	certPath := paths.CertFile
+	keyPath := paths.KeyFile
+	chainPath := paths.ChainFile
+	fullchainPath := paths.FullchainFile
+	infoPath := paths.InfoFile
 
 	// Check if certificate exists
 	if _, err := os.Stat(certPath); os.IsNotExist(err) {
 		return nil, fmt.Errorf("certificate file not found: %s", certPath)
 	}
 
-	// Check if key exists
-	if _, err := os.Stat(keyPath); os.IsNotExist(err) {
-		return nil, fmt.Errorf("private key file not found: %s", keyPath)
-	}
+	// Certificates issued from a CSR have no private key on disk
+	_, err := os.Stat(keyPath)
+	hasPrivateKey := err == nil
 
 	// Parse certificate to get domains and expiration
 	domains, expiresAt, err := acme.ParseCertificateInfo(certPath)
@@ -237,6 +259,7 @@ func buildCertificateExportInfo(certDir, dirName string, verbose bool) (*Certifi
 
 	return &CertificateExportInfo{
 		DirectoryName:  dirName,
+		Variant:        variant,
 		CertificateDir: filepath.Join(certDir, dirName),
 		Domains:        domains,
 		ExpiresAt:      expiresAt.Format("2006-01-02 15:04"),
@@ -245,6 +268,7 @@ func buildCertificateExportInfo(certDir, dirName string, verbose bool) (*Certifi
 		ChainFile:      chainPath,
 		FullchainFile:  fullchainPath,
 		InfoFile:       infoPath,
+		HasPrivateKey:  hasPrivateKey,
 	}, nil
 }
 
@@ -272,7 +296,12 @@ func showAvailableCertificates() error {
 			domainsStr = domainsStr[:30] + "..."
 		}
 
-		fmt.Printf("%-25s %-35s %s\n", cert.DirectoryName, domainsStr, cert.ExpiresAt)
+		expires := cert.ExpiresAt
+		if !cert.HasPrivateKey {
+			expires += " (no private key, cannot export)"
+		}
+
+		fmt.Printf("%-25s %-35s %s\n", utils.VariantLabel(cert.DirectoryName, cert.Variant), domainsStr, expires)
 	}
 
 	fmt.Println()
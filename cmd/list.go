@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/bariiss/flarecert/internal/acme"
+	"github.com/bariiss/flarecert/internal/utils"
 
 	"github.com/spf13/cobra"
 )
@@ -62,8 +63,8 @@ func runListCommand(cmd *cobra.Command, args []string) error {
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
 	defer w.Flush()
 
-	fmt.Fprintln(w, "DOMAIN\tDOMAINS\tEXPIRATION\tSTATUS")
-	fmt.Fprintln(w, "------\t-------\t----------\t------")
+	fmt.Fprintln(w, "DOMAIN\tDOMAINS\tEXPIRATION\tCHAIN ROOT\tSTATUS")
+	fmt.Fprintln(w, "------\t-------\t----------\t----------\t------")
 
 	found := false
 	for _, entry := range entries {
@@ -72,45 +73,78 @@ func runListCommand(cmd *cobra.Command, args []string) error {
 		}
 
 		domainName := entry.Name()
-		certPath := filepath.Join(certDir, domainName, "current", "cert.pem")
 
-		if _, err := os.Stat(certPath); os.IsNotExist(err) {
+		// Certificates issued as both RSA and ECDSA are listed once per variant
+		variants := utils.FindCertificateVariants(filepath.Join(certDir, domainName))
+		if len(variants) == 0 {
 			if verbose {
 				log.Printf("Skipping %s: no cert.pem found in current/", domainName)
 			}
 			continue
 		}
 
-		domains, expiresAt, err := acme.ParseCertificateInfo(certPath)
-		if err != nil {
-			if verbose {
-				log.Printf("Skipping %s: failed to parse certificate: %v", domainName, err)
+		for _, variant := range variants {
+			paths := utils.GetCertificatePathsForDir(filepath.Join(certDir, domainName), variant)
+			certPath := paths.CertFile
+			label := utils.VariantLabel(domainName, variant)
+
+			domains, expiresAt, err := acme.ParseCertificateInfo(certPath)
+			if err != nil {
+				if verbose {
+					log.Printf("Skipping %s: failed to parse certificate: %v", label, err)
+				}
+				continue
 			}
-			continue
-		}
 
-		found = true
+			found = true
 
-		// Determine status
-		status := "✅ Valid"
-		if expiresAt.Before(time.Now()) {
-			status = "❌ Expired"
-		} else if expiresAt.Before(time.Now().AddDate(0, 0, 30)) {
-			status = "⚠️  Expires Soon"
-		}
+			// Determine status
+			metadata, metadataErr := utils.LoadCertificateMetadata(paths.InfoFile)
+			hasMetadata := metadataErr == nil
 
-		// Format domains
-		domainsStr := strings.Join(domains, ", ")
-		if len(domainsStr) > 40 {
-			domainsStr = domainsStr[:37] + "..."
-		}
+			// Expiring soon means within a third of the certificate lifetime
+			renewBefore := acme.RenewalThresholdForFile(certPath, expiresAt)
+
+			status := "✅ Valid"
+			if hasMetadata && metadata.Revoked {
+				status = "🚫 Revoked"
+			} else if expiresAt.Before(time.Now()) {
+				status = "❌ Expired"
+			} else if expiresAt.Before(time.Now().Add(renewBefore)) {
+				status = "⚠️  Expires Soon"
+			}
 
-		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
-			domainName,
-			domainsStr,
-			expiresAt.Format("2006-01-02 15:04"),
-			status,
-		)
+			// Certificates issued from a CSR have their key stored elsewhere
+			if hasMetadata && metadata.FromCSR {
+				status += " (external key)"
+			}
+			if hasMetadata && metadata.MustStaple {
+				status += " 📌 must-staple"
+			}
+			if hasMetadata && metadata.Profile != "" {
+				status += " [" + metadata.Profile + "]"
+			}
+
+			// Show the root the served chain leads to
+			chainRoot, err := acme.ParseChainRoot(paths.ChainFile)
+			if err != nil {
+				chainRoot = "-"
+			}
+
+			// Format domains
+			domainsStr := strings.Join(domains, ", ")
+			if len(domainsStr) > 40 {
+				domainsStr = domainsStr[:37] + "..."
+			}
+
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+				label,
+				domainsStr,
+				expiresAt.Format("2006-01-02 15:04"),
+				chainRoot,
+				status,
+			)
+		}
 	}
 
 	if !found {
@@ -125,6 +159,6 @@ func printEmptyTable() {
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
 	defer w.Flush()
 
-	fmt.Fprintln(w, "DOMAIN\tDOMAINS\tEXPIRATION\tSTATUS")
-	fmt.Fprintln(w, "------\t-------\t----------\t------")
+	fmt.Fprintln(w, "DOMAIN\tDOMAINS\tEXPIRATION\tCHAIN ROOT\tSTATUS")
+	fmt.Fprintln(w, "------\t-------\t----------\t----------\t------")
 }
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -9,6 +10,8 @@ import (
 
 	"github.com/bariiss/flarecert/internal/acme"
 	"github.com/bariiss/flarecert/internal/certificate"
+	"github.com/bariiss/flarecert/internal/config"
+	"github.com/bariiss/flarecert/internal/utils"
 
 	"github.com/spf13/cobra"
 )
@@ -18,23 +21,32 @@ var renewCmd = &cobra.Command{
 	Short: "Renew existing SSL certificates",
 	Long: `Renew SSL certificates that are close to expiration.
 
-This command will scan the certificate directory and renew any certificates
-that expire within the next 30 days.`,
+This command will scan the certificate directory and ask the issuing CA for
+its ACME Renewal Information (ARI, RFC 9773) for each certificate. When the
+CA suggests a renewal window, certificates are renewed once that window is
+reached and the new order references the certificate it replaces. For CAs
+without ARI support, certificates are renewed once a third of their lifetime
+remains (30 days for 90-day certificates, 2 days for six-day short-lived
+ones), or when they expire within --days if it is set.`,
 	RunE: runRenewCommand,
 }
 
 var (
-	renewDays    int
-	renewCertDir string
-	renewAll     bool
+	renewDays          int
+	renewCertDir       string
+	renewAll           bool
+	renewNoARI         bool
+	renewSkipPreflight bool
 )
 
 func init() {
 	rootCmd.AddCommand(renewCmd)
 
-	renewCmd.Flags().IntVar(&renewDays, "days", 30, "Renew certificates expiring within this many days")
+	renewCmd.Flags().IntVar(&renewDays, "days", 0, "Renew certificates expiring within this many days (default: a third of the certificate lifetime)")
 	renewCmd.Flags().StringVar(&renewCertDir, "cert-dir", "./certs", "Directory containing certificates")
 	renewCmd.Flags().BoolVar(&renewAll, "all", false, "Renew all certificates regardless of expiration")
+	renewCmd.Flags().BoolVar(&renewSkipPreflight, "skip-preflight", false, "Skip the token, zone, CAA and ACME server checks before renewing")
+	renewCmd.Flags().BoolVar(&renewNoARI, "no-ari", false, "Ignore ACME Renewal Information and only use the --days threshold")
 }
 
 func runRenewCommand(cmd *cobra.Command, args []string) error {
@@ -44,8 +56,13 @@ func runRenewCommand(cmd *cobra.Command, args []string) error {
 		log.Println("Starting certificate renewal check...")
 	}
 
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
 	// Find certificates to renew
-	certsToRenew, err := findCertificatesForRenewal(renewCertDir, renewDays, renewAll, verbose)
+	certsToRenew, err := findCertificatesForRenewal(renewCertDir, cfg.ACMEServer, renewDays, renewAll, !renewNoARI, verbose)
 	if err != nil {
 		return fmt.Errorf("failed to find certificates for renewal: %w", err)
 	}
@@ -57,22 +74,63 @@ func runRenewCommand(cmd *cobra.Command, args []string) error {
 
 	fmt.Printf("Found %d certificate(s) to renew:\n", len(certsToRenew))
 	for _, cert := range certsToRenew {
-		fmt.Printf("  - %s (expires: %s)\n", cert.Domain, cert.ExpiresAt.Format("2006-01-02"))
+		fmt.Printf("  - %s (expires: %s, %s)\n", cert.Domain, cert.ExpiresAt.Format("2006-01-02"), cert.Reason)
+		if cert.RenewalInfo != nil && cert.RenewalInfo.ExplanationURL != "" {
+			fmt.Printf("    ℹ️  CA explanation: %s\n", cert.RenewalInfo.ExplanationURL)
+		}
 	}
 
 	// Renew each certificate
 	for _, cert := range certsToRenew {
 		fmt.Printf("\n🔄 Renewing certificate for: %s\n", cert.Domain)
 
+		// Renew against the configured CAs, so a certificate issued by a fallback CA goes back to
+		// the primary one. A CA that is not configured was selected with --ca and is kept.
+		ca := ""
+		if !cfg.IsIssuanceCA(cert.ACMEServer) {
+			ca = cert.ACMEServer
+		}
+
 		// Create certificate manager for renewal (force renew enabled)
-		manager, err := certificate.NewManager(renewCertDir, "rsa2048", false, true, verbose)
+		manager, err := certificate.NewManager(cmd.Context(), renewCertDir, cert.KeyType, ca, false, true, verbose)
 		if err != nil {
 			log.Printf("❌ Failed to create certificate manager for %s: %v", cert.Domain, err)
 			continue
 		}
 
-		// Renew certificate
-		if err := manager.GenerateCertificate(cert.Domains); err != nil {
+		// Keep the chain, profile, must-staple setting and zone selected when the certificate was first issued
+		manager.SetPreferredChain(cert.Chain)
+		manager.SetProfile(cert.Profile)
+		manager.SetMustStaple(cert.MustStaple)
+		manager.SetVariant(cert.Variant)
+		if cert.Zone != "" {
+			manager.SetZone(cert.Zone)
+		}
+
+		// Catch token, zone, CAA and ACME server problems with this certificate's zone and CA
+		if !renewSkipPreflight {
+			if err := manager.Preflight(cert.Domains); err != nil {
+				log.Printf("❌ Skipping %s: %v (run 'flarecert doctor' for details, or use --skip-preflight)", cert.Domain, err)
+				continue
+			}
+		}
+
+		// Tell the issuing CA which certificate is being replaced
+		if cert.RenewalInfo != nil {
+			manager.SetReplaces(cert.ACMEServer, cert.RenewalInfo.CertID)
+		}
+
+		// Renew certificate, reusing the stored CSR for certificates with external keys
+		if cert.CSRPath != "" {
+			_, err = manager.GenerateCertificateFromCSR(cert.CSRPath)
+		} else {
+			err = manager.GenerateCertificate(cert.Domains)
+		}
+		if err != nil {
+			// Leave the remaining certificates alone once interrupted
+			if cmd.Context().Err() != nil {
+				return err
+			}
 			log.Printf("❌ Failed to renew %s: %v", cert.Domain, err)
 			continue
 		}
@@ -84,15 +142,24 @@ func runRenewCommand(cmd *cobra.Command, args []string) error {
 }
 
 type CertificateInfo struct {
-	Domain    string
-	Domains   []string
-	ExpiresAt time.Time
-	Path      string
+	Domain      string
+	Domains     []string
+	ExpiresAt   time.Time
+	Path        string
+	ACMEServer  string
+	RenewalInfo *acme.RenewalInfo
+	Reason      string
+	CSRPath     string
+	KeyType     string
+	Chain       string
+	Profile     string
+	MustStaple  bool
+	Variant     string
+	Zone        string
 }
 
-func findCertificatesForRenewal(certDir string, days int, renewAll bool, verbose bool) ([]CertificateInfo, error) {
+func findCertificatesForRenewal(certDir, defaultServer string, days int, renewAll, useARI, verbose bool) ([]CertificateInfo, error) {
 	var certificates []CertificateInfo
-	threshold := time.Now().AddDate(0, 0, days)
 
 	entries, err := os.ReadDir(certDir)
 	if err != nil {
@@ -108,37 +175,143 @@ func findCertificatesForRenewal(certDir string, days int, renewAll bool, verbose
 		}
 
 		domainName := entry.Name()
-		// Use the new certificate structure with current subdirectory
-		certPath := filepath.Join(certDir, domainName, "current", "cert.pem")
 
-		if _, err := os.Stat(certPath); os.IsNotExist(err) {
+		// Certificates issued as both RSA and ECDSA are renewed per variant
+		variants := utils.FindCertificateVariants(filepath.Join(certDir, domainName))
+		if len(variants) == 0 {
 			if verbose {
 				log.Printf("Skipping %s: no cert.pem found in current directory", domainName)
 			}
 			continue
 		}
 
-		// Parse certificate to get expiration and domains
-		domains, expiresAt, err := acme.ParseCertificateInfo(certPath)
-		if err != nil {
-			if verbose {
-				log.Printf("Skipping %s: failed to parse certificate: %v", domainName, err)
+		for _, variant := range variants {
+			paths := utils.GetCertificatePathsForDir(filepath.Join(certDir, domainName), variant)
+			certPath := paths.CertFile
+			label := utils.VariantLabel(domainName, variant)
+
+			// Parse certificate to get expiration and domains
+			domains, expiresAt, err := acme.ParseCertificateInfo(certPath)
+			if err != nil {
+				if verbose {
+					log.Printf("Skipping %s: failed to parse certificate: %v", label, err)
+				}
+				continue
+			}
+
+			info := CertificateInfo{
+				Domain:     label,
+				Variant:    variant,
+				Domains:    domains,
+				ExpiresAt:  expiresAt,
+				Path:       certPath,
+				ACMEServer: defaultServer,
+				KeyType:    "rsa2048",
 			}
-			continue
-		}
 
-		// Check if renewal is needed
-		if renewAll || expiresAt.Before(threshold) {
-			certificates = append(certificates, CertificateInfo{
-				Domain:    domainName,
-				Domains:   domains,
-				ExpiresAt: expiresAt,
-				Path:      certPath,
-			})
-		} else if verbose {
-			log.Printf("Certificate %s is valid until %s (no renewal needed)", domainName, expiresAt.Format("2006-01-02"))
+			// Renew with the same key type and profile, and from the same CSR if it had one. The CA
+			// that issued the certificate is asked for its renewal information.
+			var window *utils.RenewalWindow
+			if metadata, err := utils.LoadCertificateMetadata(paths.InfoFile); err == nil {
+				if metadata.ACMEServer != "" {
+					info.ACMEServer = metadata.ACMEServer
+				}
+				if metadata.KeyType != "" && !metadata.FromCSR {
+					info.KeyType = metadata.KeyType
+				}
+				info.Chain = metadata.PreferredChain
+				info.Profile = metadata.Profile
+				info.MustStaple = metadata.MustStaple
+				info.Zone = metadata.Zone
+				if metadata.FromCSR {
+					info.CSRPath = paths.CSRFile
+				}
+				window = metadata.RenewalWindow
+			}
+			server := info.ACMEServer
+
+			// Prefer the CA's suggested renewal window, fall back to the day threshold
+			var needsRenewal bool
+			var renewalInfo *acme.RenewalInfo
+			if useARI {
+				renewalInfo, err = lookupRenewalInfo(server, certPath, paths.InfoFile, window, verbose)
+				if err != nil {
+					if verbose && !errors.Is(err, acme.ErrNoARI) {
+						log.Printf("ARI lookup failed for %s, using --days threshold: %v", label, err)
+					}
+				}
+			}
+
+			switch {
+			case renewAll:
+				needsRenewal = true
+				info.Reason = "forced"
+			case renewalInfo != nil:
+				needsRenewal = renewalInfo.ShouldRenew(time.Now())
+				info.Reason = fmt.Sprintf("ARI window %s - %s",
+					renewalInfo.WindowStart.Format("2006-01-02 15:04"), renewalInfo.WindowEnd.Format("2006-01-02 15:04"))
+			case days > 0:
+				needsRenewal = expiresAt.Before(time.Now().AddDate(0, 0, days))
+				info.Reason = fmt.Sprintf("%d-day threshold", days)
+			default:
+				// Scale the threshold with the lifetime so short-lived certificates renew in time
+				renewBefore := acme.RenewalThresholdForFile(certPath, expiresAt)
+				needsRenewal = expiresAt.Before(time.Now().Add(renewBefore))
+				info.Reason = fmt.Sprintf("%s threshold", formatThreshold(renewBefore))
+			}
+			info.RenewalInfo = renewalInfo
+
+			if needsRenewal {
+				certificates = append(certificates, info)
+			} else if verbose {
+				log.Printf("Certificate %s is valid until %s (no renewal needed, %s)", label, expiresAt.Format("2006-01-02"), info.Reason)
+			}
 		}
 	}
 
 	return certificates, nil
 }
+
+// lookupRenewalInfo returns the CA's renewal window for a certificate. The window stored in
+// the metadata is used until its Retry-After has passed, a fresh one is stored for next time.
+func lookupRenewalInfo(server, certPath, infoFile string, window *utils.RenewalWindow, verbose bool) (*acme.RenewalInfo, error) {
+	if window != nil && time.Now().Before(window.NextCheck) {
+		if verbose {
+			log.Printf("Using stored ARI window for %s until %s", certPath, window.NextCheck.Format("2006-01-02 15:04"))
+		}
+		return &acme.RenewalInfo{
+			CertID:         window.CertID,
+			WindowStart:    window.Start,
+			WindowEnd:      window.End,
+			ExplanationURL: window.ExplanationURL,
+		}, nil
+	}
+
+	renewalInfo, err := acme.GetRenewalInfo(server, certPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if renewalInfo.RetryAfter > 0 {
+		err := utils.SaveRenewalWindow(infoFile, utils.RenewalWindow{
+			CertID:         renewalInfo.CertID,
+			Start:          renewalInfo.WindowStart,
+			End:            renewalInfo.WindowEnd,
+			ExplanationURL: renewalInfo.ExplanationURL,
+			NextCheck:      time.Now().Add(renewalInfo.RetryAfter),
+		})
+		if err != nil && verbose {
+			log.Printf("Failed to store ARI window for %s: %v", certPath, err)
+		}
+	}
+
+	return renewalInfo, nil
+}
+
+// formatThreshold formats a renewal threshold in days, or hours when shorter than a day
+func formatThreshold(d time.Duration) string {
+	if d < 24*time.Hour {
+		return fmt.Sprintf("%d-hour", int(d.Hours()))
+	}
+	return fmt.Sprintf("%d-day", int(d.Hours()/24))
+}
@@ -0,0 +1,270 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bariiss/flarecert/internal/acme"
+	"github.com/bariiss/flarecert/internal/config"
+	"github.com/bariiss/flarecert/internal/ui"
+	"github.com/bariiss/flarecert/internal/utils"
+
+	"github.com/spf13/cobra"
+)
+
+var revokeCmd = &cobra.Command{
+	Use:   "revoke",
+	Short: "Revoke an issued SSL certificate",
+	Long: `Revoke a certificate at the ACME server that issued it.
+
+The certificate is selected either by the domain list it was issued for
+(using the same directory layout as the cert command) or by a path to a
+cert.pem file. Certificates issued as both RSA and ECDSA are revoked together
+unless --variant selects one. By default the request is signed with the stored ACME
+account; use --use-cert-key to sign with the certificate's own private key
+when the issuing account is not available.
+
+Reasons (RFC 5280): unspecified, keyCompromise, affiliationChanged,
+superseded, cessationOfOperation.
+
+Examples:
+  # Revoke a leaked certificate
+  flarecert revoke --domain example.com --reason keyCompromise
+
+  # Revoke only the ECDSA certificate of a dual RSA and ECDSA issuance
+  flarecert revoke --domain example.com --variant ecdsa --reason superseded
+
+  # Revoke a wildcard certificate by path using its own key
+  flarecert revoke --cert ./certs/wildcard-example-com/current/cert.pem --use-cert-key`,
+	RunE: runRevokeCommand,
+}
+
+var (
+	revokeDomains    []string
+	revokeCertPath   string
+	revokeCertDir    string
+	revokeReason     string
+	revokeStaging    bool
+	revokeCA         string
+	revokeUseCertKey bool
+	revokeForce      bool
+	revokeVariant    string
+)
+
+func init() {
+	rootCmd.AddCommand(revokeCmd)
+
+	revokeCmd.Flags().StringSliceVarP(&revokeDomains, "domain", "d", []string{}, "Domain name(s) of the certificate to revoke")
+	revokeCmd.Flags().StringVar(&revokeCertPath, "cert", "", "Path to the cert.pem file to revoke")
+	revokeCmd.Flags().StringVar(&revokeCertDir, "cert-dir", "./certs", "Directory containing certificates")
+	revokeCmd.Flags().StringVar(&revokeReason, "reason", "unspecified", "Revocation reason (RFC 5280)")
+	revokeCmd.Flags().BoolVar(&revokeStaging, "staging", false, "Use Let's Encrypt staging environment")
+	revokeCmd.Flags().StringVar(&revokeCA, "ca", "", "Certificate authority preset or ACME directory URL, for certificates without a recorded issuer")
+	revokeCmd.Flags().BoolVar(&revokeUseCertKey, "use-cert-key", false, "Sign the revocation with the certificate's private key")
+	revokeCmd.Flags().BoolVar(&revokeForce, "force", false, "Revoke without prompting")
+	revokeCmd.Flags().StringVar(&revokeVariant, "variant", "", "Only revoke this variant of a dual RSA and ECDSA certificate (rsa, ecdsa)")
+
+	revokeCmd.RegisterFlagCompletionFunc("domain", GetDomainCompletions)
+	revokeCmd.RegisterFlagCompletionFunc("ca", completeCAPresets)
+	revokeCmd.RegisterFlagCompletionFunc("variant", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return utils.CertificateVariants, cobra.ShellCompDirectiveNoFileComp
+	})
+	revokeCmd.RegisterFlagCompletionFunc("reason", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return acme.RevocationReasonNames(), cobra.ShellCompDirectiveNoFileComp
+	})
+}
+
+func runRevokeCommand(cmd *cobra.Command, args []string) error {
+	verbose, _ := cmd.Flags().GetBool("verbose")
+
+	if len(revokeDomains) == 0 && revokeCertPath == "" {
+		return fmt.Errorf("either --domain or --cert must be specified")
+	}
+
+	if len(revokeDomains) > 0 && revokeCertPath != "" {
+		return fmt.Errorf("cannot use both --domain and --cert flags together")
+	}
+
+	if revokeVariant != "" {
+		if revokeCertPath != "" {
+			return fmt.Errorf("--variant selects a certificate issued for --domain, it cannot be used with --cert")
+		}
+		known := false
+		for _, variant := range utils.CertificateVariants {
+			known = known || variant == revokeVariant
+		}
+		if !known {
+			return fmt.Errorf("unknown variant %q (valid: %s)", revokeVariant, strings.Join(utils.CertificateVariants, ", "))
+		}
+	}
+
+	reason, err := acme.ParseRevocationReason(revokeReason)
+	if err != nil {
+		return err
+	}
+
+	// Resolve certificate files, every RSA and ECDSA variant of a domain list unless one is selected
+	certPaths := []string{revokeCertPath}
+	if revokeCertPath == "" {
+		certPaths = revokeCertificatePaths(revokeCertDir, revokeDomains, revokeVariant)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	// Revoke at the CA that issued the certificate, --ca and --staging apply when it is unknown
+	if err := cfg.SelectCA(revokeCA, revokeStaging); err != nil {
+		return err
+	}
+
+	var targets []revokeTarget
+	for _, certPath := range certPaths {
+		target, err := loadRevokeTarget(certPath, cfg.ACMEServer)
+		if err != nil {
+			return err
+		}
+
+		// Only the issuing CA can revoke the certificate, an explicit CA must not silently lose
+		if (revokeCA != "" || revokeStaging) && target.server != cfg.ACMEServer {
+			return fmt.Errorf("certificate %s was issued by %s, not by the selected CA %s; omit --ca and --staging to revoke it at its issuer",
+				certPath, target.server, cfg.ACMEServer)
+		}
+
+		if target.revoked {
+			if len(certPaths) == 1 {
+				return fmt.Errorf("certificate %s has already been revoked", certPath)
+			}
+			fmt.Printf("⏭️  Skipping %s: already revoked\n", certPath)
+			continue
+		}
+		targets = append(targets, target)
+	}
+
+	if len(targets) == 0 {
+		return fmt.Errorf("all certificates of %s have already been revoked", strings.Join(revokeDomains, ", "))
+	}
+
+	for _, target := range targets {
+		fmt.Printf("🗑️  Certificate: %s\n", target.certPath)
+		fmt.Printf("   Domains: %s\n", strings.Join(target.domains, ", "))
+		fmt.Printf("   Expires: %s\n", target.expiresAt.Format("2006-01-02 15:04"))
+	}
+	fmt.Printf("   Reason:  %s\n", revokeReason)
+
+	prompt := "Do you want to revoke this certificate? This cannot be undone"
+	if len(targets) > 1 {
+		prompt = fmt.Sprintf("Do you want to revoke these %d certificates? This cannot be undone", len(targets))
+	}
+	if !revokeForce && !ui.AskUserConfirmation(prompt) {
+		fmt.Println("Certificate revocation cancelled.")
+		return nil
+	}
+
+	for _, target := range targets {
+		if err := revokeCertificate(target, cfg, reason, verbose); err != nil {
+			return err
+		}
+
+		if err := utils.MarkCertificateRevoked(target.infoPath, revokeReason); err != nil {
+			log.Printf("Warning: failed to record revocation in %s: %v", target.infoPath, err)
+		}
+
+		fmt.Printf("✅ Certificate revoked: %s\n", target.certPath)
+	}
+
+	return nil
+}
+
+// revokeTarget is a certificate selected for revocation
+type revokeTarget struct {
+	certPath  string
+	keyPath   string
+	infoPath  string
+	certPEM   []byte
+	domains   []string
+	expiresAt time.Time
+	server    string
+	revoked   bool
+}
+
+// revokeCertificatePaths returns the cert.pem files of a domain list, one per RSA/ECDSA variant,
+// or only the selected variant. The regular path is returned when none exists, to report it.
+func revokeCertificatePaths(certDir string, domains []string, variant string) []string {
+	if variant != "" {
+		return []string{utils.GetCertificatePathsForVariant(certDir, domains, variant).CertFile}
+	}
+
+	var certPaths []string
+	for _, v := range utils.FindCertificateVariants(utils.GetCertificateDirForDomains(certDir, domains)) {
+		certPaths = append(certPaths, utils.GetCertificatePathsForVariant(certDir, domains, v).CertFile)
+	}
+	if len(certPaths) == 0 {
+		certPaths = append(certPaths, utils.GetCertificatePathsForDomains(certDir, domains).CertFile)
+	}
+	return certPaths
+}
+
+// loadRevokeTarget reads a certificate and the CA that issued it, server when it is unknown
+func loadRevokeTarget(certPath, server string) (revokeTarget, error) {
+	currentDir := filepath.Dir(certPath)
+	target := revokeTarget{
+		certPath: certPath,
+		keyPath:  filepath.Join(currentDir, "privkey.pem"),
+		infoPath: filepath.Join(currentDir, "cert.json"),
+		server:   server,
+	}
+
+	certPEM, err := os.ReadFile(certPath)
+	if err != nil {
+		return target, fmt.Errorf("failed to read certificate: %w", err)
+	}
+	target.certPEM = certPEM
+
+	target.domains, target.expiresAt, err = acme.ParseCertificateInfo(certPath)
+	if err != nil {
+		return target, err
+	}
+
+	if metadata, err := utils.LoadCertificateMetadata(target.infoPath); err == nil {
+		target.revoked = metadata.Revoked
+		if metadata.ACMEServer != "" {
+			target.server = metadata.ACMEServer
+		}
+	}
+
+	return target, nil
+}
+
+// revokeCertificate revokes a certificate with the stored ACME account or its own key
+func revokeCertificate(target revokeTarget, cfg *config.Config, reason uint, verbose bool) error {
+	if revokeUseCertKey {
+		keyPEM, err := os.ReadFile(target.keyPath)
+		if err != nil {
+			return fmt.Errorf("failed to read certificate private key: %w", err)
+		}
+
+		return acme.RevokeWithCertificateKey(target.server, target.certPEM, keyPEM, reason, verbose)
+	}
+
+	store := acme.NewAccountStore(revokeCertDir)
+	user, err := store.Load(target.server, cfg.ACMEEmail)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("no ACME account found for %s on %s, use --use-cert-key to revoke with the certificate key", cfg.ACMEEmail, target.server)
+		}
+		return err
+	}
+
+	client, err := acme.NewAccountClient(store, user, verbose)
+	if err != nil {
+		return err
+	}
+
+	return client.Revoke(target.certPEM, reason)
+}
@@ -1,6 +1,13 @@
 package cmd
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
 	"github.com/spf13/cobra"
 )
 
@@ -14,9 +21,32 @@ This tool is specifically designed to work with Cloudflare-proxied domains
 (orange cloud enabled) and supports wildcard certificates.`,
 }
 
+// ExitInterrupted is the exit code used when a command is stopped by SIGINT or SIGTERM
+const ExitInterrupted = 130
+
+// ErrInterrupted is returned by Execute when the command was stopped by SIGINT or SIGTERM
+var ErrInterrupted = errors.New("interrupted")
+
 // Execute adds all child commands to the root command and sets flags appropriately.
+// The command context is cancelled on SIGINT or SIGTERM so that running orders stop
+// and their challenge records are removed; a second signal exits immediately.
 func Execute() error {
-	return rootCmd.Execute()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	err := rootCmd.ExecuteContext(ctx)
+	if ctx.Err() != nil {
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInterrupted, err)
+		}
+		return ErrInterrupted
+	}
+	return err
 }
 
 func init() {
@@ -17,12 +17,22 @@ var zonesCmd = &cobra.Command{
 	Long: `List all available Cloudflare zones in your account.
 
 This command shows all zones that you can use for certificate generation,
-along with their status and other information.`,
+along with their status and other information.
+
+Zones are cached under <CERT_DIR>/.cache/zones.json for ZONE_CACHE_TTL seconds
+(default 3600) to avoid Cloudflare API rate limits. Use --refresh to reload them.`,
 	RunE: runZonesCommand,
 }
 
+var (
+	zonesRefresh bool
+	zonesCertDir string
+)
+
 func init() {
 	rootCmd.AddCommand(zonesCmd)
+	zonesCmd.Flags().BoolVar(&zonesRefresh, "refresh", false, "Invalidate the zone cache and reload zones from Cloudflare")
+	zonesCmd.Flags().StringVar(&zonesCertDir, "cert-dir", "./certs", "Directory containing certificates and the zone cache")
 }
 
 func runZonesCommand(cmd *cobra.Command, args []string) error {
@@ -39,13 +49,19 @@ func runZonesCommand(cmd *cobra.Command, args []string) error {
 	}
 
 	// Create Cloudflare DNS provider
-	provider, err := dns.NewCloudflareProvider(cfg.CloudflareAPIToken, cfg.CloudflareEmail, cfg.DNSTimeout, verbose)
+	provider, err := dns.NewProviderFromConfig(cmd.Context(), cfg, zonesCertDir, verbose)
 	if err != nil {
 		return fmt.Errorf("failed to create Cloudflare provider: %w", err)
 	}
-
-	// List zones
-	zones, err := provider.ListZones()
+	defer provider.LogRetrySummary()
+
+	// List zones, from the cache unless a refresh is requested
+	var zones []dns.ZoneInfo
+	if zonesRefresh {
+		zones, err = provider.RefreshZones()
+	} else {
+		zones, err = provider.ListZones()
+	}
 	if err != nil {
 		return fmt.Errorf("failed to list zones: %w", err)
 	}
@@ -60,8 +76,15 @@ func runZonesCommand(cmd *cobra.Command, args []string) error {
 	defer w.Flush()
 
 	fmt.Printf("📋 Found %d zone(s) in your Cloudflare account:\n\n", len(zones))
-	fmt.Fprintln(w, "STATUS\tZONE NAME\tZONE ID")
-	fmt.Fprintln(w, "------\t---------\t-------")
+	// Show which account manages each zone only when several are configured
+	multiAccount := len(provider.Accounts()) > 1
+	if multiAccount {
+		fmt.Fprintln(w, "STATUS\tZONE NAME\tZONE ID\tACCOUNT")
+		fmt.Fprintln(w, "------\t---------\t-------\t-------")
+	} else {
+		fmt.Fprintln(w, "STATUS\tZONE NAME\tZONE ID")
+		fmt.Fprintln(w, "------\t---------\t-------")
+	}
 
 	for _, zone := range zones {
 		status := "✅ Active"
@@ -69,13 +92,21 @@ func runZonesCommand(cmd *cobra.Command, args []string) error {
 			status = fmt.Sprintf("⚠️  %s", zone.Status)
 		}
 
-		fmt.Fprintf(w, "%s\t%s\t%s\n", status, zone.Name, zone.ID)
+		if multiAccount {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", status, zone.Name, zone.ID, zone.Account)
+		} else {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", status, zone.Name, zone.ID)
+		}
 	}
 
 	fmt.Println("\n💡 Tips:")
 	fmt.Println("  - Only active zones can be used for certificate generation")
 	fmt.Println("  - FlareCert will automatically detect the right zone for your domain")
-	fmt.Println("  - If multiple zones match, you'll be prompted to choose")
+	fmt.Println("  - The most specific zone wins when several zones match a domain")
+	fmt.Println("  - Use --zone or CLOUDFLARE_ZONE_MAP to pick a zone explicitly (required without a terminal)")
+	if multiAccount {
+		fmt.Println("  - Use CLOUDFLARE_ZONE_ACCOUNTS to pin a zone visible to several accounts")
+	}
 
 	return nil
 }
@@ -0,0 +1,195 @@
+package acme
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-acme/lego/v4/certcrypto"
+	"github.com/go-acme/lego/v4/registration"
+)
+
+// AccountStore persists ACME account keys and registrations under the certificate directory
+type AccountStore struct {
+	dir string
+}
+
+// accountFile is the on-disk representation of an ACME account
+type accountFile struct {
+	Email        string                 `json:"email"`
+	Server       string                 `json:"server"`
+	Registration *registration.Resource `json:"registration,omitempty"`
+	CreatedAt    time.Time              `json:"created_at"`
+}
+
+// NewAccountStore creates an account store rooted at <certDir>/accounts
+func NewAccountStore(certDir string) *AccountStore {
+	return &AccountStore{
+		dir: filepath.Join(certDir, "accounts"),
+	}
+}
+
+// AccountDir returns the directory holding the account for the given server and email
+func (s *AccountStore) AccountDir(server, email string) string {
+	return filepath.Join(s.dir, serverDirName(server), email)
+}
+
+// Load loads the account key and registration for the given server and email.
+// It returns an error satisfying os.IsNotExist if no account key has been stored yet.
+func (s *AccountStore) Load(server, email string) (*User, error) {
+	accountDir := s.AccountDir(server, email)
+
+	keyData, err := os.ReadFile(filepath.Join(accountDir, "account.key"))
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := certcrypto.ParsePEMPrivateKey(keyData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse account key: %w", err)
+	}
+
+	user := &User{
+		Email:  email,
+		Server: server,
+		key:    key,
+	}
+
+	data, err := os.ReadFile(filepath.Join(accountDir, "account.json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			// Key without registration, the caller resolves it by key
+			return user, nil
+		}
+		return nil, fmt.Errorf("failed to read account file: %w", err)
+	}
+
+	var stored accountFile
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal account file: %w", err)
+	}
+
+	user.Registration = stored.Registration
+	user.CreatedAt = stored.CreatedAt
+
+	return user, nil
+}
+
+// StoredAccountURI returns the URI of the account stored for server and email, empty when
+// no account has been registered yet
+func StoredAccountURI(certDir, server, email string) string {
+	user, err := NewAccountStore(certDir).Load(server, email)
+	if err != nil || user.Registration == nil {
+		return ""
+	}
+	return user.Registration.URI
+}
+
+// Save writes the account key and registration to disk
+func (s *AccountStore) Save(user *User) error {
+	accountDir := s.AccountDir(user.Server, user.Email)
+	if err := os.MkdirAll(accountDir, 0700); err != nil {
+		return fmt.Errorf("failed to create account directory: %w", err)
+	}
+
+	keyData := certcrypto.PEMEncode(user.key)
+	if err := os.WriteFile(filepath.Join(accountDir, "account.key"), keyData, 0600); err != nil {
+		return fmt.Errorf("failed to write account key: %w", err)
+	}
+
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = time.Now()
+	}
+
+	data, err := json.MarshalIndent(accountFile{
+		Email:        user.Email,
+		Server:       user.Server,
+		Registration: user.Registration,
+		CreatedAt:    user.CreatedAt,
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal account file: %w", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(accountDir, "account.json"), data, 0600); err != nil {
+		return fmt.Errorf("failed to write account file: %w", err)
+	}
+
+	return nil
+}
+
+// List returns all accounts stored under the account directory
+func (s *AccountStore) List() ([]*User, error) {
+	var users []*User
+
+	servers, err := os.ReadDir(s.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return users, nil
+		}
+		return nil, fmt.Errorf("failed to read account directory: %w", err)
+	}
+
+	for _, server := range servers {
+		if !server.IsDir() {
+			continue
+		}
+
+		emails, err := os.ReadDir(filepath.Join(s.dir, server.Name()))
+		if err != nil {
+			continue
+		}
+
+		for _, email := range emails {
+			if !email.IsDir() {
+				continue
+			}
+
+			data, err := os.ReadFile(filepath.Join(s.dir, server.Name(), email.Name(), "account.json"))
+			if err != nil {
+				continue
+			}
+
+			var stored accountFile
+			if err := json.Unmarshal(data, &stored); err != nil {
+				continue
+			}
+
+			user, err := s.Load(stored.Server, stored.Email)
+			if err != nil {
+				continue
+			}
+
+			users = append(users, user)
+		}
+	}
+
+	return users, nil
+}
+
+// Delete removes the stored account for the given server and email
+func (s *AccountStore) Delete(server, email string) error {
+	if err := os.RemoveAll(s.AccountDir(server, email)); err != nil {
+		return fmt.Errorf("failed to remove account directory: %w", err)
+	}
+	return nil
+}
+
+// serverDirName converts an ACME directory URL into a directory name
+func serverDirName(server string) string {
+	u, err := url.Parse(server)
+	if err != nil || u.Host == "" {
+		return strings.NewReplacer("://", "_", "/", "_", ":", "_").Replace(server)
+	}
+
+	name := u.Host
+	if path := strings.Trim(u.Path, "/"); path != "" {
+		name += "_" + strings.ReplaceAll(path, "/", "_")
+	}
+
+	return strings.ReplaceAll(name, ":", "_")
+}
@@ -1,13 +1,14 @@
 package acme
 
 import (
+	"context"
 	"crypto"
-	"crypto/rand"
-	"crypto/rsa"
 	"crypto/x509"
+	"encoding/asn1"
 	"encoding/pem"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"time"
 
@@ -16,15 +17,21 @@ import (
 
 	"github.com/go-acme/lego/v4/certcrypto"
 	"github.com/go-acme/lego/v4/certificate"
+	"github.com/go-acme/lego/v4/challenge/dns01"
 	"github.com/go-acme/lego/v4/lego"
 	"github.com/go-acme/lego/v4/registration"
 )
 
+// accountKeyType is the key type used for newly generated account keys
+const accountKeyType = certcrypto.RSA2048
+
 // Client wraps the ACME client with our configuration
 type Client struct {
-	client  *lego.Client
-	config  *config.Config
-	verbose bool
+	ctx      context.Context
+	client   *lego.Client
+	provider *dns.CloudflareProvider
+	config   *config.Config
+	verbose  bool
 }
 
 // CertificateResult holds the certificate data
@@ -33,13 +40,48 @@ type CertificateResult struct {
 	PrivateKey        []byte
 	IssuerCertificate []byte
 	NotAfter          time.Time
+	Issuer            string
+	ACMEServer        string
+	MustStaple        bool
+}
+
+// ObtainOptions holds optional parameters for a certificate order
+type ObtainOptions struct {
+	// ReplacesCertID is the ARI identifier of the certificate this order replaces
+	ReplacesCertID string
+	// CSR is a user-supplied certificate signing request, the private key stays with the caller
+	CSR *x509.CertificateRequest
+	// PrivateKey is an existing key to request the certificate for instead of generating one
+	PrivateKey crypto.PrivateKey
+	// PreferredChain is the common name of the root the issuer chain should lead to, if the CA offers it
+	PreferredChain string
+	// Profile is the ACME certificate profile to request, such as shortlived
+	Profile string
+	// MustStaple requests the TLS Feature (status_request) extension, ignored for CSRs which carry their own
+	MustStaple bool
 }
 
 // User represents the ACME user
 type User struct {
 	Email        string
+	Server       string
 	Registration *registration.Resource
-	key          *rsa.PrivateKey
+	CreatedAt    time.Time
+	key          crypto.PrivateKey
+}
+
+// NewUser creates a new ACME user with a freshly generated account key
+func NewUser(server, email string) (*User, error) {
+	privateKey, err := certcrypto.GeneratePrivateKey(accountKeyType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate private key: %w", err)
+	}
+
+	return &User{
+		Email:  email,
+		Server: server,
+		key:    privateKey,
+	}, nil
 }
 
 // GetEmail returns the user's email
@@ -58,87 +100,140 @@ func (u *User) GetPrivateKey() crypto.PrivateKey {
 }
 
 // NewClient creates a new ACME client with Cloudflare DNS provider
-func NewClient(cfg *config.Config, verbose bool, keyType string) (*Client, error) {
-	// Generate user private key
-	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+func NewClient(ctx context.Context, cfg *config.Config, verbose bool, keyType string) (*Client, error) {
+	// Load the persisted account, or create a new one on first use
+	store := NewAccountStore(cfg.CertDir)
+	user, err := store.Load(cfg.ACMEServer, cfg.ACMEEmail)
+	existingKey := err == nil
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate private key: %w", err)
-	}
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to load ACME account: %w", err)
+		}
 
-	// Create user
-	user := &User{
-		Email: cfg.ACMEEmail,
-		key:   privateKey,
+		user, err = NewUser(cfg.ACMEServer, cfg.ACMEEmail)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	// Create lego config
-	legoConfig := lego.NewConfig(user)
-	legoConfig.CADirURL = cfg.ACMEServer
-
-	// Set key type for certificates
-	switch keyType {
-	case "rsa2048":
-		legoConfig.Certificate.KeyType = certcrypto.RSA2048
-	case "rsa4096":
-		legoConfig.Certificate.KeyType = certcrypto.RSA4096
-	case "ec256":
-		legoConfig.Certificate.KeyType = certcrypto.EC256
-	case "ec384":
-		legoConfig.Certificate.KeyType = certcrypto.EC384
-	default:
-		legoConfig.Certificate.KeyType = certcrypto.RSA2048
+	if user.Registration != nil && user.Registration.Body.Status == "deactivated" {
+		return nil, fmt.Errorf("ACME account %s is deactivated, remove %s to register a new one",
+			user.Registration.URI, store.AccountDir(user.Server, user.Email))
 	}
 
-	// Create lego client
-	client, err := lego.NewClient(legoConfig)
+	// Key type for certificates
+	certKeyType, err := ParseKeyType(keyType)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create lego client: %w", err)
+		return nil, err
 	}
 
 	// Create Cloudflare DNS provider
-	provider, err := dns.NewCloudflareProvider(cfg.CloudflareAPIToken, cfg.CloudflareEmail, cfg.DNSTimeout, verbose)
+	provider, err := dns.NewProviderFromConfig(ctx, cfg, cfg.CertDir, verbose)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Cloudflare provider: %w", err)
 	}
 
+	// Create lego config, requests lego still makes after the order was abandoned must not reach the CA
+	legoConfig := lego.NewConfig(user)
+	legoConfig.CADirURL = cfg.ACMEServer
+	legoConfig.HTTPClient.Transport = &orderTransport{base: legoConfig.HTTPClient.Transport, provider: provider}
+	legoConfig.Certificate.KeyType = certKeyType
+
+	// Create lego client
+	client, err := lego.NewClient(legoConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create lego client: %w", err)
+	}
+
 	// Set DNS challenge provider
-	err = client.Challenge.SetDNS01Provider(provider)
+	err = client.Challenge.SetDNS01Provider(provider, dns01.WrapPreCheck(provider.PreCheck))
 	if err != nil {
 		return nil, fmt.Errorf("failed to set DNS provider: %w", err)
 	}
 
-	// Register user
-	reg, err := client.Registration.Register(registration.RegisterOptions{TermsOfServiceAgreed: true})
+	// Register user unless the account is already known to the server
+	if err := ensureRegistration(client, user, cfg, existingKey, verbose); err != nil {
+		return nil, err
+	}
+
+	if err := store.Save(user); err != nil {
+		return nil, fmt.Errorf("failed to save ACME account: %w", err)
+	}
+
+	return &Client{
+		ctx:      ctx,
+		client:   client,
+		provider: provider,
+		config:   cfg,
+		verbose:  verbose,
+	}, nil
+}
+
+// ensureRegistration reuses the stored registration, resolves it by account key, or registers a new account
+func ensureRegistration(client *lego.Client, user *User, cfg *config.Config, existingKey, verbose bool) error {
+	if user.Registration != nil {
+		if verbose {
+			log.Printf("Using existing ACME account: %s", user.Registration.URI)
+		}
+		return nil
+	}
+
+	if existingKey {
+		// Key was loaded from disk without a registration, try to find the account
+		if reg, err := client.Registration.ResolveAccountByKey(); err == nil {
+			user.Registration = reg
+			if verbose {
+				log.Printf("Resolved existing ACME account by key: %s", reg.URI)
+			}
+			return nil
+		}
+	}
+
+	var reg *registration.Resource
+	var err error
+	if cfg.EABKeyID != "" {
+		reg, err = client.Registration.RegisterWithExternalAccountBinding(registration.RegisterEABOptions{
+			TermsOfServiceAgreed: true,
+			Kid:                  cfg.EABKeyID,
+			HmacEncoded:          cfg.EABHMACKey,
+		})
+	} else if cfg.RequiresEAB() {
+		return fmt.Errorf("ACME server %s requires External Account Binding, set ACME_EAB_KID and ACME_EAB_HMAC", cfg.ACMEServer)
+	} else {
+		reg, err = client.Registration.Register(registration.RegisterOptions{TermsOfServiceAgreed: true})
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to register user: %w", err)
+		return fmt.Errorf("failed to register user: %w", err)
 	}
 	user.Registration = reg
 
 	if verbose {
-		log.Printf("ACME client registered with server: %s", cfg.ACMEServer)
+		log.Printf("ACME account registered with server %s: %s", user.Server, reg.URI)
 	}
 
-	return &Client{
-		client:  client,
-		config:  cfg,
-		verbose: verbose,
-	}, nil
+	return nil
 }
 
 // ObtainCertificate requests a new certificate for the given domains
-func (c *Client) ObtainCertificate(domains []string) (*CertificateResult, error) {
+func (c *Client) ObtainCertificate(domains []string, opts ObtainOptions) (*CertificateResult, error) {
+	defer c.provider.LogRetrySummary()
+
 	if c.verbose {
 		log.Printf("Requesting certificate for domains: %v", domains)
+		if opts.ReplacesCertID != "" {
+			log.Printf("Order replaces certificate: %s", opts.ReplacesCertID)
+		}
 	}
 
-	// Create certificate request
-	request := certificate.ObtainRequest{
-		Domains: domains,
-		Bundle:  true,
+	profile := opts.Profile
+	if profile != "" {
+		var err error
+		if profile, err = c.resolveProfile(profile); err != nil {
+			return nil, err
+		}
 	}
 
-	// Obtain certificate
-	certificates, err := c.client.Certificate.Obtain(request)
+	certificates, err := c.obtain(domains, opts, profile)
 	if err != nil {
 		return nil, fmt.Errorf("failed to obtain certificate: %w", err)
 	}
@@ -163,49 +258,124 @@ func (c *Client) ObtainCertificate(domains []string) (*CertificateResult, error)
 		PrivateKey:        certificates.PrivateKey,
 		IssuerCertificate: certificates.IssuerCertificate,
 		NotAfter:          cert.NotAfter,
+		Issuer:            cert.Issuer.CommonName,
+		ACMEServer:        c.config.ACMEServer,
+		MustStaple:        HasMustStaple(cert),
 	}, nil
 }
 
-// IsCertificateValid checks if a certificate exists and is valid for the given domains
-func IsCertificateValid(certPath string, domains []string) (bool, error) {
-	// Check if certificate file exists
-	certData, err := os.ReadFile(certPath)
-	if err != nil {
-		return false, err
+// obtain runs the lego order and returns as soon as the run is interrupted or a challenge
+// record cannot be created. lego cannot be cancelled, so the challenge records presented
+// so far are removed before returning.
+func (c *Client) obtain(domains []string, opts ObtainOptions, profile string) (*certificate.Resource, error) {
+	type result struct {
+		certificates *certificate.Resource
+		err          error
 	}
 
-	// Parse certificate
-	block, _ := pem.Decode(certData)
-	if block == nil {
-		return false, fmt.Errorf("failed to parse certificate PEM")
-	}
+	done := make(chan result, 1)
+	go func() {
+		var res result
+
+		// Obtain certificate, either for the CSR or with a key generated by lego
+		if opts.CSR != nil {
+			res.certificates, res.err = c.client.Certificate.ObtainForCSR(certificate.ObtainForCSRRequest{
+				CSR:            opts.CSR,
+				Bundle:         true,
+				PreferredChain: opts.PreferredChain,
+				Profile:        profile,
+				ReplacesCertID: opts.ReplacesCertID,
+			})
+		} else {
+			res.certificates, res.err = c.client.Certificate.Obtain(certificate.ObtainRequest{
+				Domains:        domains,
+				PrivateKey:     opts.PrivateKey,
+				MustStaple:     opts.MustStaple,
+				Bundle:         true,
+				PreferredChain: opts.PreferredChain,
+				Profile:        profile,
+				ReplacesCertID: opts.ReplacesCertID,
+			})
+		}
+		done <- res
+	}()
+
+	// Closing the provider stops lego from creating records and waiting for them, and its
+	// requests to the CA fail, so it gives up shortly
+	abandon := func() {
+		c.provider.Close()
+		if err := c.provider.CleanUpAll(); err != nil {
+			log.Printf("❌ %v", err)
+		}
 
-	cert, err := x509.ParseCertificate(block.Bytes)
-	if err != nil {
-		return false, fmt.Errorf("failed to parse certificate: %w", err)
+		select {
+		case <-done:
+		case <-time.After(abandonTimeout):
+			if c.verbose {
+				log.Printf("ACME client did not stop within %v", abandonTimeout)
+			}
+		}
 	}
 
-	// Check expiration (consider valid if expires more than 30 days from now)
-	if time.Now().Add(30 * 24 * time.Hour).After(cert.NotAfter) {
-		return false, nil // Certificate expires within 30 days
+	select {
+	case res := <-done:
+		return res.certificates, res.err
+	case <-c.ctx.Done():
+		fmt.Println("🧹 Interrupted, removing challenge records...")
+		abandon()
+		return nil, c.ctx.Err()
+	case err := <-c.provider.CreateFailed():
+		fmt.Println("🧹 Order abandoned, removing challenge records...")
+		abandon()
+		return nil, err
 	}
+}
 
-	// Check if all requested domains are covered
-	certDomains := make(map[string]bool)
-	if cert.Subject.CommonName != "" {
-		certDomains[cert.Subject.CommonName] = true
-	}
-	for _, domain := range cert.DNSNames {
-		certDomains[domain] = true
+// abandonTimeout bounds how long an abandoned order waits for lego to return
+const abandonTimeout = 30 * time.Second
+
+// orderTransport rejects the requests to the CA once the order was abandoned
+type orderTransport struct {
+	base     http.RoundTripper
+	provider *dns.CloudflareProvider
+}
+
+// RoundTrip sends the request unless the order was abandoned
+func (t *orderTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.provider.Err(); err != nil {
+		return nil, fmt.Errorf("order abandoned: %w", err)
 	}
+	return t.base.RoundTrip(req)
+}
+
+// oidTLSFeature is the TLS Feature extension (RFC 7633) used for OCSP Must-Staple
+var oidTLSFeature = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 24}
 
-	for _, domain := range domains {
-		if !certDomains[domain] {
-			return false, nil // Domain not covered by certificate
+// HasMustStaple reports whether the certificate carries the TLS Feature extension
+func HasMustStaple(cert *x509.Certificate) bool {
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(oidTLSFeature) {
+			return true
 		}
 	}
+	return false
+}
 
-	return true, nil
+// RenewalThreshold returns how long before expiry a certificate should be renewed.
+// It scales with the certificate lifetime: a third of it, which is 30 days for
+// 90-day certificates and 2 days for six-day short-lived certificates.
+func RenewalThreshold(notBefore, notAfter time.Time) time.Duration {
+	return notAfter.Sub(notBefore) / 3
+}
+
+// RenewalThresholdForFile returns the renewal threshold of the certificate at certPath,
+// assuming a 90-day lifetime when its validity cannot be read
+func RenewalThresholdForFile(certPath string, expiresAt time.Time) time.Duration {
+	notBefore, _, err := ParseCertificateValidity(certPath)
+	if err != nil {
+		notBefore = expiresAt.AddDate(0, 0, -90)
+	}
+	return RenewalThreshold(notBefore, expiresAt)
 }
 
 // ParseCertificateInfo extracts domain names and expiration from a certificate file
@@ -245,3 +415,53 @@ func ParseCertificateInfo(certPath string) ([]string, time.Time, error) {
 
 	return domains, cert.NotAfter, nil
 }
+
+// ParseChainRoot returns the common name of the root certificate the issuer chain at chainPath leads to
+func ParseChainRoot(chainPath string) (string, error) {
+	chainData, err := os.ReadFile(chainPath)
+	if err != nil {
+		return "", err
+	}
+
+	// The root itself is not part of the chain, the last intermediate names it as issuer
+	var last *x509.Certificate
+	for {
+		var block *pem.Block
+		block, chainData = pem.Decode(chainData)
+		if block == nil {
+			break
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse chain certificate: %w", err)
+		}
+		last = cert
+	}
+
+	if last == nil {
+		return "", fmt.Errorf("no certificates found in chain")
+	}
+
+	return last.Issuer.CommonName, nil
+}
+
+// ParseCertificateValidity returns the validity period of the certificate at certPath
+func ParseCertificateValidity(certPath string) (time.Time, time.Time, error) {
+	certData, err := os.ReadFile(certPath)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	block, _ := pem.Decode(certData)
+	if block == nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("failed to parse certificate PEM")
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+
+	return cert.NotBefore, cert.NotAfter, nil
+}
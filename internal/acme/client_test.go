@@ -0,0 +1,84 @@
+package acme
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRenewalThreshold(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		lifetime time.Duration
+		want     time.Duration
+	}{
+		{"90 days", 90 * 24 * time.Hour, 30 * 24 * time.Hour},
+		{"45 days", 45 * 24 * time.Hour, 15 * 24 * time.Hour},
+		{"6 days", 6 * 24 * time.Hour, 2 * 24 * time.Hour},
+		{"160 hours", 160 * time.Hour, 160 * time.Hour / 3},
+	}
+
+	for _, tt := range tests {
+		if got := RenewalThreshold(start, start.Add(tt.lifetime)); got != tt.want {
+			t.Errorf("%s: RenewalThreshold() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRenewalThresholdForFile(t *testing.T) {
+	dir := t.TempDir()
+	notBefore := time.Now().Truncate(time.Second).Add(-time.Hour)
+	notAfter := notBefore.Add(6 * 24 * time.Hour)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		DNSNames:     []string{"example.com"},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shortLived := filepath.Join(dir, "cert.pem")
+	if err := os.WriteFile(shortLived, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	garbage := filepath.Join(dir, "garbage.pem")
+	if err := os.WriteFile(garbage, []byte("not a certificate"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		certPath  string
+		expiresAt time.Time
+		want      time.Duration
+	}{
+		{"short-lived certificate", shortLived, notAfter, 2 * 24 * time.Hour},
+		{"missing file assumes 90 days", filepath.Join(dir, "missing.pem"), notAfter, 30 * 24 * time.Hour},
+		{"unreadable file assumes 90 days", garbage, notAfter, 30 * 24 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := RenewalThresholdForFile(tt.certPath, tt.expiresAt); got != tt.want {
+			t.Errorf("%s: RenewalThresholdForFile() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package acme
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+
+	"github.com/go-acme/lego/v4/certcrypto"
+)
+
+// LoadCSR reads a PEM or DER encoded certificate signing request and verifies its signature
+func LoadCSR(path string) (*x509.CertificateRequest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSR: %w", err)
+	}
+
+	var csr *x509.CertificateRequest
+	if block, _ := pem.Decode(data); block != nil {
+		csr, err = certcrypto.PemDecodeTox509CSR(data)
+	} else {
+		csr, err = x509.ParseCertificateRequest(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CSR: %w", err)
+	}
+
+	if err := csr.CheckSignature(); err != nil {
+		return nil, fmt.Errorf("invalid CSR signature: %w", err)
+	}
+
+	return csr, nil
+}
+
+// CSRDomains returns the common name and DNS names of a CSR without duplicates
+func CSRDomains(csr *x509.CertificateRequest) []string {
+	return certcrypto.ExtractDomainsCSR(csr)
+}
+
+// EncodeCSR returns the PEM encoding of a CSR
+func EncodeCSR(csr *x509.CertificateRequest) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr.Raw})
+}
+
+// PublicKeyType returns the key type name (as used by --key-type) of a public key
+func PublicKeyType(pub crypto.PublicKey) string {
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("rsa%d", k.N.BitLen())
+	case *ecdsa.PublicKey:
+		return fmt.Sprintf("ec%d", k.Curve.Params().BitSize)
+	}
+	return "unknown"
+}
+
+// LoadPrivateKey reads a PEM encoded private key from disk
+func LoadPrivateKey(path string) (crypto.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := certcrypto.ParsePEMPrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+
+	return signer, nil
+}
@@ -0,0 +1,50 @@
+package acme
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/go-acme/lego/v4/acme"
+)
+
+// IsRetryableError reports whether an issuance error is caused by the CA at server being
+// unavailable or rate limiting, so the order may succeed at another CA. Errors of the DNS
+// provider are not, another CA would run into them as well.
+func IsRetryableError(err error, server string) bool {
+	if err == nil {
+		return false
+	}
+
+	var problem *acme.ProblemDetails
+	if errors.As(err, &problem) {
+		switch problem.Type {
+		case "urn:ietf:params:acme:error:rateLimited", "urn:ietf:params:acme:error:serverInternal":
+			return true
+		}
+		return problem.HTTPStatus == http.StatusTooManyRequests || problem.HTTPStatus >= http.StatusInternalServerError
+	}
+
+	// Only transport errors against the ACME server count, not those against Cloudflare
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return isACMEHost(urlErr.URL, server)
+	}
+
+	return false
+}
+
+// isACMEHost reports whether rawURL points at the host of the ACME directory server
+func isACMEHost(rawURL, server string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+
+	directory, err := url.Parse(server)
+	if err != nil {
+		return false
+	}
+
+	return u.Host != "" && u.Host == directory.Host
+}
@@ -0,0 +1,36 @@
+package acme
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/go-acme/lego/v4/acme"
+)
+
+func TestIsRetryableError(t *testing.T) {
+	server := "https://acme.example.com/directory"
+	refused := errors.New("connection refused")
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"rate limited", &acme.ProblemDetails{Type: "urn:ietf:params:acme:error:rateLimited", HTTPStatus: http.StatusTooManyRequests}, true},
+		{"server error", fmt.Errorf("failed to obtain certificate: %w", &acme.ProblemDetails{HTTPStatus: http.StatusServiceUnavailable}), true},
+		{"rejected identifier", &acme.ProblemDetails{Type: "urn:ietf:params:acme:error:rejectedIdentifier", HTTPStatus: http.StatusBadRequest}, false},
+		{"ACME server unreachable", &url.Error{Op: "Post", URL: "https://acme.example.com/new-order", Err: refused}, true},
+		{"Cloudflare unreachable", fmt.Errorf("failed to create DNS provider: %w", &url.Error{Op: "Get", URL: "https://api.cloudflare.com/client/v4/user/tokens/verify", Err: refused}), false},
+		{"other error", errors.New("DNS record not found"), false},
+		{"nil", nil, false},
+	}
+
+	for _, tt := range tests {
+		if got := IsRetryableError(tt.err, server); got != tt.want {
+			t.Errorf("%s: IsRetryableError() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package acme
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-acme/lego/v4/acme"
+	jose "github.com/go-jose/go-jose/v4"
+)
+
+// keyChangeRequest is the inner payload of an RFC 8555 key rollover request
+type keyChangeRequest struct {
+	Account string          `json:"account"`
+	OldKey  jose.JSONWebKey `json:"oldKey"`
+}
+
+// nonceSource fetches fresh anti-replay nonces from the ACME server
+type nonceSource struct {
+	httpClient *http.Client
+	url        string
+}
+
+// Nonce returns a new nonce from the server's newNonce endpoint
+func (n *nonceSource) Nonce() (string, error) {
+	resp, err := n.httpClient.Head(n.url)
+	if err != nil {
+		return "", fmt.Errorf("failed to get nonce: %w", err)
+	}
+	defer resp.Body.Close()
+
+	nonce := resp.Header.Get("Replay-Nonce")
+	if nonce == "" {
+		return "", fmt.Errorf("server did not return a nonce")
+	}
+
+	return nonce, nil
+}
+
+// rolloverAccountKey replaces the account key on the ACME server (RFC 8555 section 7.3.5)
+func rolloverAccountKey(server, accountURL string, oldKey, newKey crypto.PrivateKey) error {
+	httpClient := &http.Client{Timeout: 30 * time.Second}
+
+	dir, err := fetchDirectory(httpClient, server)
+	if err != nil {
+		return err
+	}
+
+	if dir.KeyChangeURL == "" {
+		return fmt.Errorf("ACME server does not support key rollover")
+	}
+
+	// Inner JWS: signed by the new key, proving possession of it
+	oldJWK := jose.JSONWebKey{Key: publicKey(oldKey)}
+	innerPayload, err := json.Marshal(keyChangeRequest{
+		Account: accountURL,
+		OldKey:  oldJWK,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal key change request: %w", err)
+	}
+
+	innerSigner, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: signatureAlgorithm(newKey), Key: newKey},
+		&jose.SignerOptions{
+			EmbedJWK:     true,
+			ExtraHeaders: map[jose.HeaderKey]interface{}{"url": dir.KeyChangeURL},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create signer for new key: %w", err)
+	}
+
+	inner, err := innerSigner.Sign(innerPayload)
+	if err != nil {
+		return fmt.Errorf("failed to sign key change request: %w", err)
+	}
+
+	// Outer JWS: signed by the current account key
+	outerSigner, err := jose.NewSigner(
+		jose.SigningKey{
+			Algorithm: signatureAlgorithm(oldKey),
+			Key:       jose.JSONWebKey{Key: oldKey, KeyID: accountURL},
+		},
+		&jose.SignerOptions{
+			NonceSource:  &nonceSource{httpClient: httpClient, url: dir.NewNonceURL},
+			ExtraHeaders: map[jose.HeaderKey]interface{}{"url": dir.KeyChangeURL},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create signer for account key: %w", err)
+	}
+
+	outer, err := outerSigner.Sign([]byte(inner.FullSerialize()))
+	if err != nil {
+		return fmt.Errorf("failed to sign key change request: %w", err)
+	}
+
+	resp, err := httpClient.Post(dir.KeyChangeURL, "application/jose+json", bytes.NewBufferString(outer.FullSerialize()))
+	if err != nil {
+		return fmt.Errorf("failed to send key change request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("key change rejected by server (HTTP %d): %s", resp.StatusCode, string(body))
+	}
+
+	return nil
+}
+
+// fetchDirectory retrieves the ACME directory document
+func fetchDirectory(httpClient *http.Client, server string) (*acme.Directory, error) {
+	resp, err := httpClient.Get(server)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch ACME directory: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch ACME directory: HTTP %d", resp.StatusCode)
+	}
+
+	var dir acme.Directory
+	if err := json.NewDecoder(resp.Body).Decode(&dir); err != nil {
+		return nil, fmt.Errorf("failed to decode ACME directory: %w", err)
+	}
+
+	return &dir, nil
+}
+
+// signatureAlgorithm returns the JWS algorithm matching the key
+func signatureAlgorithm(key crypto.PrivateKey) jose.SignatureAlgorithm {
+	if k, ok := key.(*ecdsa.PrivateKey); ok {
+		switch k.Curve {
+		case elliptic.P384():
+			return jose.ES384
+		case elliptic.P521():
+			return jose.ES512
+		default:
+			return jose.ES256
+		}
+	}
+	return jose.RS256
+}
+
+// publicKey returns the public half of a private key
+func publicKey(key crypto.PrivateKey) crypto.PublicKey {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return k.Public()
+	case *ecdsa.PrivateKey:
+		return k.Public()
+	}
+	return nil
+}
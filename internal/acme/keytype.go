@@ -0,0 +1,48 @@
+package acme
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-acme/lego/v4/certcrypto"
+)
+
+// KeyTypeNames lists the accepted certificate key types
+var KeyTypeNames = []string{"rsa2048", "rsa3072", "rsa4096", "rsa8192", "ec256", "ec384"}
+
+// keyTypes maps the accepted key type names to lego key types
+var keyTypes = map[string]certcrypto.KeyType{
+	"rsa2048": certcrypto.RSA2048,
+	"rsa3072": certcrypto.RSA3072,
+	"rsa4096": certcrypto.RSA4096,
+	"rsa8192": certcrypto.RSA8192,
+	"ec256":   certcrypto.EC256,
+	"ec384":   certcrypto.EC384,
+}
+
+// ParseKeyType converts a key type name into a lego key type, rejecting unknown names
+func ParseKeyType(name string) (certcrypto.KeyType, error) {
+	keyType, ok := keyTypes[name]
+	if !ok {
+		return "", fmt.Errorf("unknown key type %q (valid: %s)", name, strings.Join(KeyTypeNames, ", "))
+	}
+	return keyType, nil
+}
+
+// KeyTypeSize splits a key type name such as rsa3072 or ec256 into its algorithm and size in bits
+func KeyTypeSize(name string) (string, int, error) {
+	for _, algorithm := range []string{"rsa", "ec"} {
+		if !strings.HasPrefix(name, algorithm) {
+			continue
+		}
+
+		bits, err := strconv.Atoi(strings.TrimPrefix(name, algorithm))
+		if err != nil {
+			break
+		}
+		return algorithm, bits, nil
+	}
+
+	return "", 0, fmt.Errorf("unknown key type %q", name)
+}
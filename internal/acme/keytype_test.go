@@ -0,0 +1,60 @@
+package acme
+
+import (
+	"testing"
+
+	"github.com/go-acme/lego/v4/certcrypto"
+)
+
+func TestParseKeyType(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    certcrypto.KeyType
+		wantErr bool
+	}{
+		{"rsa2048", certcrypto.RSA2048, false},
+		{"rsa3072", certcrypto.RSA3072, false},
+		{"rsa4096", certcrypto.RSA4096, false},
+		{"rsa8192", certcrypto.RSA8192, false},
+		{"ec256", certcrypto.EC256, false},
+		{"ec384", certcrypto.EC384, false},
+		{"ecc256", "", true},
+		{"ec521", "", true},
+		{"rsa1024", "", true},
+		{"RSA2048", "", true},
+		{"", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseKeyType(tt.name)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("ParseKeyType(%q) = %q, %v, want %q, error %v", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestKeyTypeSize(t *testing.T) {
+	tests := []struct {
+		name      string
+		algorithm string
+		bits      int
+		wantErr   bool
+	}{
+		{"rsa2048", "rsa", 2048, false},
+		{"rsa3072", "rsa", 3072, false},
+		{"rsa8192", "rsa", 8192, false},
+		{"ec256", "ec", 256, false},
+		{"ec384", "ec", 384, false},
+		{"ecc256", "", 0, true},
+		{"rsa", "", 0, true},
+		{"dsa1024", "", 0, true},
+	}
+
+	for _, tt := range tests {
+		algorithm, bits, err := KeyTypeSize(tt.name)
+		if algorithm != tt.algorithm || bits != tt.bits || (err != nil) != tt.wantErr {
+			t.Errorf("KeyTypeSize(%q) = %q, %d, %v, want %q, %d, error %v",
+				tt.name, algorithm, bits, err, tt.algorithm, tt.bits, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package acme
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-acme/lego/v4/acme"
+)
+
+// CheckDirectory fetches the ACME directory of a server and returns the offset of the local
+// clock from the server's Date header (positive when the local clock is ahead)
+func CheckDirectory(server string) (time.Duration, error) {
+	httpClient := &http.Client{Timeout: 15 * time.Second}
+
+	resp, err := httpClient.Get(server)
+	if err != nil {
+		return 0, fmt.Errorf("failed to reach ACME directory: %w", err)
+	}
+	defer resp.Body.Close()
+	now := time.Now()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("failed to fetch ACME directory: HTTP %d", resp.StatusCode)
+	}
+
+	var dir acme.Directory
+	if err := json.NewDecoder(resp.Body).Decode(&dir); err != nil {
+		return 0, fmt.Errorf("failed to decode ACME directory: %w", err)
+	}
+	if dir.NewOrderURL == "" {
+		return 0, fmt.Errorf("%s is not an ACME directory", server)
+	}
+
+	date, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return 0, nil
+	}
+
+	// The Date header has a one second resolution
+	return now.Sub(date).Truncate(time.Second), nil
+}
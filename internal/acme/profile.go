@@ -0,0 +1,47 @@
+package acme
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// KnownProfiles lists the certificate profiles offered by Let's Encrypt
+var KnownProfiles = []string{"classic", "tlsserver", "shortlived"}
+
+// ListProfiles returns the certificate profiles advertised in the server's directory, keyed by name
+func ListProfiles(server string) (map[string]string, error) {
+	dir, err := fetchDirectory(&http.Client{Timeout: 30 * time.Second}, server)
+	if err != nil {
+		return nil, err
+	}
+
+	return dir.Meta.Profiles, nil
+}
+
+// resolveProfile checks the requested profile against the server's directory.
+// Servers without profile support get the order without a profile.
+func (c *Client) resolveProfile(profile string) (string, error) {
+	profiles, err := ListProfiles(c.config.ACMEServer)
+	if err != nil {
+		return "", err
+	}
+
+	if len(profiles) == 0 {
+		log.Printf("Warning: %s does not advertise certificate profiles, ignoring profile %q", c.config.ACMEServer, profile)
+		return "", nil
+	}
+
+	if _, ok := profiles[profile]; !ok {
+		names := make([]string, 0, len(profiles))
+		for name := range profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return "", fmt.Errorf("ACME server %s does not offer profile %q (available: %v)", c.config.ACMEServer, profile, names)
+	}
+
+	return profile, nil
+}
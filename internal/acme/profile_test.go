@@ -0,0 +1,43 @@
+package acme
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bariiss/flarecert/internal/config"
+)
+
+func TestResolveProfile(t *testing.T) {
+	directory := func(body string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(body))
+		}))
+	}
+
+	withProfiles := directory(`{"meta":{"profiles":{"classic":"Classic","shortlived":"Six days"}}}`)
+	defer withProfiles.Close()
+	withoutProfiles := directory(`{"meta":{}}`)
+	defer withoutProfiles.Close()
+
+	tests := []struct {
+		name    string
+		server  string
+		profile string
+		want    string
+		wantErr bool
+	}{
+		{"offered", withProfiles.URL, "shortlived", "shortlived", false},
+		{"not offered", withProfiles.URL, "tlsserver", "", true},
+		{"no profile support", withoutProfiles.URL, "shortlived", "", false},
+	}
+
+	for _, tt := range tests {
+		c := &Client{config: &config.Config{ACMEServer: tt.server}}
+		got, err := c.resolveProfile(tt.profile)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("%s: resolveProfile(%q) = %q, %v, want %q, error %v", tt.name, tt.profile, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
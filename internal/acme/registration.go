@@ -0,0 +1,131 @@
+package acme
+
+import (
+	"crypto"
+	"encoding/base64"
+	"fmt"
+	"log"
+
+	"github.com/go-acme/lego/v4/certcrypto"
+	"github.com/go-acme/lego/v4/lego"
+	"github.com/go-acme/lego/v4/registration"
+	jose "github.com/go-jose/go-jose/v4"
+)
+
+// AccountClient performs account management operations for a stored ACME account
+type AccountClient struct {
+	client  *lego.Client
+	store   *AccountStore
+	user    *User
+	verbose bool
+}
+
+// NewAccountClient creates a client for managing an existing, registered account
+func NewAccountClient(store *AccountStore, user *User, verbose bool) (*AccountClient, error) {
+	if user.Registration == nil {
+		return nil, fmt.Errorf("account %s has no registration on %s", user.Email, user.Server)
+	}
+
+	legoConfig := lego.NewConfig(user)
+	legoConfig.CADirURL = user.Server
+
+	client, err := lego.NewClient(legoConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create lego client: %w", err)
+	}
+
+	return &AccountClient{
+		client:  client,
+		store:   store,
+		user:    user,
+		verbose: verbose,
+	}, nil
+}
+
+// Query refreshes the registration resource from the ACME server and stores it
+func (a *AccountClient) Query() (*registration.Resource, error) {
+	reg, err := a.client.Registration.QueryRegistration()
+	if err != nil {
+		return nil, fmt.Errorf("failed to query account: %w", err)
+	}
+
+	a.user.Registration = reg
+	if err := a.store.Save(a.user); err != nil {
+		return nil, err
+	}
+
+	return reg, nil
+}
+
+// UpdateContact changes the account contact email and moves the stored account accordingly
+func (a *AccountClient) UpdateContact(email string) error {
+	oldEmail := a.user.Email
+	a.user.Email = email
+
+	reg, err := a.client.Registration.UpdateRegistration(registration.RegisterOptions{TermsOfServiceAgreed: true})
+	if err != nil {
+		a.user.Email = oldEmail
+		return fmt.Errorf("failed to update account contact: %w", err)
+	}
+	a.user.Registration = reg
+
+	if err := a.store.Save(a.user); err != nil {
+		return err
+	}
+
+	if oldEmail != email {
+		if err := a.store.Delete(a.user.Server, oldEmail); err != nil {
+			return err
+		}
+	}
+
+	if a.verbose {
+		log.Printf("Account contact updated: %s -> %s", oldEmail, email)
+	}
+
+	return nil
+}
+
+// RolloverKey replaces the account key with a newly generated one
+func (a *AccountClient) RolloverKey() error {
+	newKey, err := certcrypto.GeneratePrivateKey(accountKeyType)
+	if err != nil {
+		return fmt.Errorf("failed to generate private key: %w", err)
+	}
+
+	if err := rolloverAccountKey(a.user.Server, a.user.Registration.URI, a.user.key, newKey); err != nil {
+		return err
+	}
+
+	a.user.key = newKey
+	if err := a.store.Save(a.user); err != nil {
+		return fmt.Errorf("key rolled over on server but failed to save new key: %w", err)
+	}
+
+	if a.verbose {
+		log.Printf("Account key rolled over for %s", a.user.Registration.URI)
+	}
+
+	return nil
+}
+
+// Deactivate permanently deactivates the account on the ACME server
+func (a *AccountClient) Deactivate() error {
+	if err := a.client.Registration.DeleteRegistration(); err != nil {
+		return fmt.Errorf("failed to deactivate account: %w", err)
+	}
+
+	a.user.Registration.Body.Status = "deactivated"
+	return a.store.Save(a.user)
+}
+
+// KeyThumbprint returns the RFC 7638 SHA-256 thumbprint of the account key
+func (u *User) KeyThumbprint() (string, error) {
+	jwk := jose.JSONWebKey{Key: publicKey(u.key)}
+	thumbprint, err := jwk.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return "", fmt.Errorf("failed to compute key thumbprint: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(thumbprint), nil
+}
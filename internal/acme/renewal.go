@@ -0,0 +1,130 @@
+package acme
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/go-acme/lego/v4/certificate"
+)
+
+// ErrNoARI is returned when the ACME server does not advertise a renewalInfo endpoint
+var ErrNoARI = errors.New("ACME server does not support renewal information (ARI)")
+
+// Bounds applied to the CA's Retry-After before renewal information is fetched again
+const (
+	minRetryAfter = time.Minute
+	maxRetryAfter = 24 * time.Hour
+)
+
+// RenewalInfo holds the CA's suggested renewal window for a certificate (RFC 9773)
+type RenewalInfo struct {
+	CertID         string
+	WindowStart    time.Time
+	WindowEnd      time.Time
+	ExplanationURL string
+	RetryAfter     time.Duration
+}
+
+// ShouldRenew reports whether the certificate should be renewed now.
+// A random time within the suggested window is selected as recommended by RFC 9773.
+func (r *RenewalInfo) ShouldRenew(now time.Time) bool {
+	info := certificate.RenewalInfoResponse{}
+	info.SuggestedWindow.Start = r.WindowStart
+	info.SuggestedWindow.End = r.WindowEnd
+
+	return info.ShouldRenewAt(now, 0) != nil
+}
+
+// GetRenewalInfo queries the server's renewalInfo endpoint for the certificate at certPath.
+// It returns ErrNoARI if the server does not advertise ARI support.
+func GetRenewalInfo(server, certPath string) (*RenewalInfo, error) {
+	certData, err := os.ReadFile(certPath)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(certData)
+	if block == nil {
+		return nil, fmt.Errorf("failed to parse certificate PEM")
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+
+	certID, err := certificate.MakeARICertID(cert)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build ARI certificate ID: %w", err)
+	}
+
+	httpClient := &http.Client{Timeout: 30 * time.Second}
+
+	dir, err := fetchDirectory(httpClient, server)
+	if err != nil {
+		return nil, err
+	}
+
+	if dir.RenewalInfo == "" {
+		return nil, ErrNoARI
+	}
+
+	resp, err := httpClient.Get(dir.RenewalInfo + "/" + certID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch renewal information: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch renewal information: HTTP %d", resp.StatusCode)
+	}
+
+	var info certificate.RenewalInfoResponse
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, fmt.Errorf("failed to decode renewal information: %w", err)
+	}
+
+	result := &RenewalInfo{
+		CertID:         certID,
+		WindowStart:    info.SuggestedWindow.Start,
+		WindowEnd:      info.SuggestedWindow.End,
+		ExplanationURL: info.ExplanationURL,
+	}
+
+	if retry := resp.Header.Get("Retry-After"); retry != "" {
+		if retryAfter, ok := parseRetryAfter(retry, time.Now()); ok {
+			result.RetryAfter = retryAfter
+		}
+	}
+
+	return result, nil
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as an HTTP date. The
+// result is clamped to the polling limits suggested by RFC 9773.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	var retryAfter time.Duration
+	if seconds, err := strconv.Atoi(value); err == nil {
+		retryAfter = time.Duration(seconds) * time.Second
+	} else if date, err := http.ParseTime(value); err == nil {
+		retryAfter = date.Sub(now)
+	} else {
+		return 0, false
+	}
+
+	if retryAfter < minRetryAfter {
+		retryAfter = minRetryAfter
+	}
+	if retryAfter > maxRetryAfter {
+		retryAfter = maxRetryAfter
+	}
+
+	return retryAfter, true
+}
@@ -0,0 +1,30 @@
+package acme
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{"21600", 6 * time.Hour, true},
+		{"Thu, 01 Jan 2026 18:00:00 GMT", 6 * time.Hour, true},
+		{"5", time.Minute, true},
+		{"Wed, 31 Dec 2025 12:00:00 GMT", time.Minute, true},
+		{"604800", 24 * time.Hour, true},
+		{"soon", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseRetryAfter(tt.value, now)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("parseRetryAfter(%q) = %v, %v, want %v, %v", tt.value, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package acme
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/go-acme/lego/v4/certcrypto"
+	"github.com/go-acme/lego/v4/lego"
+)
+
+// RevocationReasons maps the RFC 5280 reason names accepted by ACME servers to their codes
+var RevocationReasons = map[string]uint{
+	"unspecified":          0,
+	"keyCompromise":        1,
+	"affiliationChanged":   3,
+	"superseded":           4,
+	"cessationOfOperation": 5,
+}
+
+// ParseRevocationReason converts an RFC 5280 reason name into its code
+func ParseRevocationReason(name string) (uint, error) {
+	reason, ok := RevocationReasons[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown revocation reason %q (valid: %v)", name, RevocationReasonNames())
+	}
+	return reason, nil
+}
+
+// RevocationReasonNames returns the accepted revocation reason names in code order
+func RevocationReasonNames() []string {
+	names := make([]string, 0, len(RevocationReasons))
+	for name := range RevocationReasons {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return RevocationReasons[names[i]] < RevocationReasons[names[j]]
+	})
+	return names
+}
+
+// Revoke revokes a PEM encoded certificate using the account key
+func (a *AccountClient) Revoke(certPEM []byte, reason uint) error {
+	if err := a.client.Certificate.RevokeWithReason(certPEM, &reason); err != nil {
+		return fmt.Errorf("failed to revoke certificate: %w", err)
+	}
+
+	if a.verbose {
+		log.Printf("Certificate revoked by account %s (reason %d)", a.user.Registration.URI, reason)
+	}
+
+	return nil
+}
+
+// RevokeWithCertificateKey revokes a PEM encoded certificate by signing the request with its own private key
+func RevokeWithCertificateKey(server string, certPEM, keyPEM []byte, reason uint, verbose bool) error {
+	key, err := certcrypto.ParsePEMPrivateKey(keyPEM)
+	if err != nil {
+		return fmt.Errorf("failed to parse certificate private key: %w", err)
+	}
+
+	// An unregistered user makes lego sign with the embedded JWK instead of an account URL
+	user := &User{
+		Server: server,
+		key:    key,
+	}
+
+	legoConfig := lego.NewConfig(user)
+	legoConfig.CADirURL = server
+
+	client, err := lego.NewClient(legoConfig)
+	if err != nil {
+		return fmt.Errorf("failed to create lego client: %w", err)
+	}
+
+	if err := client.Certificate.RevokeWithReason(certPEM, &reason); err != nil {
+		return fmt.Errorf("failed to revoke certificate: %w", err)
+	}
+
+	if verbose {
+		log.Printf("Certificate revoked with its own key on %s (reason %d)", server, reason)
+	}
+
+	return nil
+}
@@ -1,6 +1,9 @@
 package certificate
 
 import (
+	"context"
+	"crypto"
+	"crypto/x509"
 	"fmt"
 	"os"
 	"strings"
@@ -8,33 +11,75 @@ import (
 
 	"github.com/bariiss/flarecert/internal/acme"
 	"github.com/bariiss/flarecert/internal/config"
+	"github.com/bariiss/flarecert/internal/dns"
+	"github.com/bariiss/flarecert/internal/preflight"
 	"github.com/bariiss/flarecert/internal/ui"
 	"github.com/bariiss/flarecert/internal/utils"
 )
 
 // Manager handles certificate operations
 type Manager struct {
-	config     *config.Config
-	certDir    string
-	verbose    bool
-	staging    bool
-	keyType    string
-	forceRenew bool
+	ctx           context.Context
+	config        *config.Config
+	certDir       string
+	verbose       bool
+	staging       bool
+	keyType       string
+	forceRenew    bool
+	replaces      string
+	replacesCA    string
+	chain         string
+	chainSet      bool
+	profile       string
+	profileSet    bool
+	mustStaple    bool
+	mustStapleSet bool
+	variant       string
+	zone          string
+	zoneSet       bool
+
+	reuseKey         bool
+	reuseKeySet      bool
+	maxKeyAgeDays    int
+	maxKeyAgeDaysSet bool
 }
 
-// NewManager creates a new certificate manager
-func NewManager(certDir, keyType string, staging, forceRenew, verbose bool) (*Manager, error) {
+// DefaultMaxKeyAgeDays is the key age after which a reused private key is replaced anyway
+const DefaultMaxKeyAgeDays = 365
+
+// keyInfo describes the private key of an issued certificate
+type keyInfo struct {
+	createdAt     time.Time
+	reuse         bool
+	maxKeyAgeDays int
+}
+
+// NewManager creates a new certificate manager. Cancelling ctx interrupts a running order.
+func NewManager(ctx context.Context, certDir, keyType, ca string, staging, forceRenew, verbose bool) (*Manager, error) {
 	cfg, err := config.Load()
 	if err != nil {
 		return nil, fmt.Errorf("failed to load configuration: %w", err)
 	}
 
-	// Override staging if flag is set
-	if staging {
-		cfg.ACMEServer = "https://acme-staging-v02.api.letsencrypt.org/directory"
+	// Override the ACME server if --ca or --staging is set
+	if err := cfg.SelectCA(ca, staging); err != nil {
+		return nil, err
+	}
+
+	// Keep ACME accounts alongside the certificates they issue
+	if certDir != "" {
+		cfg.CertDir = certDir
+	}
+
+	// Reject unknown key types before anything is requested
+	for _, kt := range SplitKeyTypes(keyType) {
+		if _, err := acme.ParseKeyType(kt); err != nil {
+			return nil, err
+		}
 	}
 
 	return &Manager{
+		ctx:        ctx,
 		config:     cfg,
 		certDir:    certDir,
 		verbose:    verbose,
@@ -44,8 +89,189 @@ func NewManager(certDir, keyType string, staging, forceRenew, verbose bool) (*Ma
 	}, nil
 }
 
-// GenerateCertificate generates a new certificate for the given domains
+// SetReplaces sets the ARI identifier of the certificate the next order replaces and
+// the ACME server that issued it, the only CA the identifier is known to
+func (m *Manager) SetReplaces(server, certID string) {
+	m.replacesCA = server
+	m.replaces = certID
+}
+
+// SetPreferredChain sets the root common name the issuer chain should lead to,
+// overriding the one persisted with the certificate
+func (m *Manager) SetPreferredChain(name string) {
+	m.chain = name
+	m.chainSet = true
+}
+
+// SetProfile sets the ACME certificate profile to request, overriding the persisted one
+func (m *Manager) SetProfile(profile string) {
+	m.profile = profile
+	m.profileSet = true
+}
+
+// SetMustStaple requests the OCSP Must-Staple extension, overriding the persisted setting
+func (m *Manager) SetMustStaple(enabled bool) {
+	m.mustStaple = enabled
+	m.mustStapleSet = true
+}
+
+// SetVariant stores the certificate as the given key type variant of a dual RSA and ECDSA certificate
+func (m *Manager) SetVariant(variant string) {
+	m.variant = variant
+}
+
+// SplitKeyTypes splits a comma separated --key-type value
+func SplitKeyTypes(keyType string) []string {
+	var keyTypes []string
+	for _, kt := range strings.Split(keyType, ",") {
+		if kt = strings.TrimSpace(kt); kt != "" {
+			keyTypes = append(keyTypes, kt)
+		}
+	}
+	return keyTypes
+}
+
+// SetZone sets the Cloudflare zone (name or ID) holding the challenge records of all domains,
+// overriding the persisted one. An empty zone goes back to zone detection.
+func (m *Manager) SetZone(zone string) {
+	m.zone = zone
+	m.zoneSet = true
+}
+
+// configFor returns a copy of the configuration with the zone of the certificate for domains:
+// the one set with SetZone, otherwise the one persisted with the certificate
+func (m *Manager) configFor(domains []string) *config.Config {
+	cfg := *m.config
+	cfg.Zone = m.zone
+	if !m.zoneSet {
+		paths := utils.GetCertificatePathsForVariant(m.certDir, domains, m.variant)
+		existing, _ := utils.LoadCertificateMetadata(paths.InfoFile)
+		cfg.Zone = existing.Zone
+	}
+	return &cfg
+}
+
+// SetReuseKey overrides the persisted key reuse setting of the certificate
+func (m *Manager) SetReuseKey(enabled bool) {
+	m.reuseKey = enabled
+	m.reuseKeySet = true
+}
+
+// SetMaxKeyAge overrides the persisted age limit of a reused key. 0 disables the limit.
+func (m *Manager) SetMaxKeyAge(days int) {
+	m.maxKeyAgeDays = days
+	m.maxKeyAgeDaysSet = true
+}
+
+// GenerateCertificate generates a new certificate for the given domains.
+// With one RSA and one ECDSA key type both certificates are issued and stored side by side.
 func (m *Manager) GenerateCertificate(domains []string) error {
+	keyTypes := SplitKeyTypes(m.keyType)
+	for _, keyType := range keyTypes {
+		if err := m.checkKeyPolicy(keyType); err != nil {
+			return err
+		}
+	}
+
+	if len(keyTypes) <= 1 {
+		return m.generate(domains, nil)
+	}
+
+	seen := make(map[string]string)
+	for _, keyType := range keyTypes {
+		variant := utils.KeyVariant(keyType)
+		if other, ok := seen[variant]; ok {
+			return fmt.Errorf("key types %s and %s are both %s, combine one RSA and one ECDSA key type", other, keyType, variant)
+		}
+		seen[variant] = keyType
+	}
+
+	for _, keyType := range keyTypes {
+		variantManager := *m
+		variantManager.keyType = keyType
+		variantManager.variant = utils.KeyVariant(keyType)
+
+		fmt.Printf("🔑 Issuing %s certificate (%s)\n", variantManager.variant, keyType)
+		if err := variantManager.generate(domains, nil); err != nil {
+			return fmt.Errorf("%s certificate: %w", keyType, err)
+		}
+	}
+
+	// The side by side certificates replace a single certificate issued earlier
+	single := utils.GetCertificatePathsForDomains(m.certDir, domains)
+	if err := utils.ArchiveOldCertificate(single); err != nil && m.verbose {
+		fmt.Printf("Warning: failed to archive single certificate: %v\n", err)
+	}
+
+	return nil
+}
+
+// GenerateCertificateFromCSR issues a certificate for a user-supplied CSR.
+// The private key never leaves its owner, so no privkey.pem is written.
+func (m *Manager) GenerateCertificateFromCSR(csrPath string) ([]string, error) {
+	csr, err := acme.LoadCSR(csrPath)
+	if err != nil {
+		return nil, err
+	}
+
+	domains := acme.CSRDomains(csr)
+	if len(domains) == 0 {
+		return nil, fmt.Errorf("CSR does not contain any domain names")
+	}
+
+	if err := m.checkKeyPolicy(acme.PublicKeyType(csr.PublicKey)); err != nil {
+		return nil, err
+	}
+
+	// Make sure every SAN can be validated through Cloudflare before placing the order
+	cfg := m.configFor(domains)
+	provider, err := dns.NewProviderFromConfig(m.ctx, cfg, cfg.CertDir, m.verbose)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Cloudflare provider: %w", err)
+	}
+
+	if err := provider.ValidateDomains(domains); err != nil {
+		return nil, err
+	}
+
+	return domains, m.generate(domains, csr)
+}
+
+// Preflight checks the certificate directory, the CAs, the CAA records and the Cloudflare token
+// and zones of the domains before an order is placed, printing warnings and failures with their fixes
+func (m *Manager) Preflight(domains []string) error {
+	report := preflight.Run(m.ctx, preflight.Options{
+		Config:  m.configFor(domains),
+		Domains: domains,
+		CAA:     true,
+		Verbose: m.verbose,
+	})
+	report.Print(true)
+
+	return report.Err()
+}
+
+// checkKeyPolicy enforces the configured minimum key sizes (MIN_RSA_KEY_SIZE, MIN_EC_KEY_SIZE)
+func (m *Manager) checkKeyPolicy(keyType string) error {
+	algorithm, bits, err := acme.KeyTypeSize(keyType)
+	if err != nil {
+		return err
+	}
+
+	minBits := m.config.MinRSAKeySize
+	if algorithm == "ec" {
+		minBits = m.config.MinECKeySize
+	}
+
+	if bits < minBits {
+		return fmt.Errorf("key type %s violates the key policy: %s keys must be at least %d bits", keyType, strings.ToUpper(algorithm), minBits)
+	}
+
+	return nil
+}
+
+// generate issues and stores a certificate, using the CSR when one is given
+func (m *Manager) generate(domains []string, csr *x509.CertificateRequest) error {
 	// Validate input
 	if len(domains) == 0 {
 		return fmt.Errorf("at least one domain must be specified")
@@ -64,12 +290,11 @@ func (m *Manager) GenerateCertificate(domains []string) error {
 	}
 
 	// Get certificate paths using domain list (prioritizes wildcard)
-	paths := utils.GetCertificatePathsForDomains(m.certDir, domains)
-
-	// Initialize ACME client
-	client, err := acme.NewClient(m.config, m.verbose, m.keyType)
-	if err != nil {
-		return fmt.Errorf("failed to create ACME client: %w", err)
+	paths := utils.GetCertificatePathsForVariant(m.certDir, domains, m.variant)
+	for _, dir := range []string{paths.CurrentDir, paths.ArchiveDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create directory %s: %w", dir, err)
+		}
 	}
 
 	// Check existing certificate and determine action
@@ -86,28 +311,44 @@ func (m *Manager) GenerateCertificate(domains []string) error {
 		// Continue with certificate generation
 	}
 
-	// Archive old certificate if it exists
-	if err := utils.ArchiveOldCertificate(paths); err != nil {
-		if m.verbose {
-			fmt.Printf("Warning: failed to archive old certificate: %v\n", err)
-		}
+	// Keep the settings persisted with the certificate unless they were set explicitly
+	existing, _ := utils.LoadCertificateMetadata(paths.InfoFile)
+	cfg := m.configFor(domains)
+	if !m.chainSet {
+		m.chain = existing.PreferredChain
+	}
+	if !m.profileSet {
+		m.profile = existing.Profile
 	}
+	if !m.mustStapleSet && csr == nil {
+		m.mustStaple = existing.MustStaple
+	}
+
+	// Reuse the existing private key if requested and still acceptable
+	privateKey, key := m.reusableKey(paths, existing, csr)
 
 	// Generate certificate
 	fmt.Printf("🔐 Generating certificate for: %s\n", utils.FormatDomainForDisplay(domains))
 
-	cert, err := client.ObtainCertificate(domains)
+	cert, err := m.obtainCertificate(cfg, domains, csr, privateKey)
 	if err != nil {
 		return fmt.Errorf("failed to obtain certificate: %w", err)
 	}
 
+	// Archive old certificate now that its replacement has been issued
+	if err := utils.ArchiveOldCertificate(paths); err != nil {
+		if m.verbose {
+			fmt.Printf("Warning: failed to archive old certificate: %v\n", err)
+		}
+	}
+
 	// Save certificate files
-	if err := m.saveCertificateFiles(cert, paths); err != nil {
+	if err := m.saveCertificateFiles(cert, paths, csr); err != nil {
 		return err
 	}
 
 	// Save certificate metadata
-	if err := m.saveCertificateMetadata(domains, paths, cert); err != nil {
+	if err := m.saveCertificateMetadata(domains, paths, cert, csr, key, cfg.Zone); err != nil {
 		if m.verbose {
 			fmt.Printf("Warning: failed to save metadata: %v\n", err)
 		}
@@ -122,10 +363,73 @@ func (m *Manager) GenerateCertificate(domains []string) error {
 
 	fmt.Printf("✅ Certificate successfully generated and saved to: %s\n", paths.CurrentDir)
 	fmt.Printf("📅 Certificate expires: %s\n", cert.NotAfter.Format("2006-01-02 15:04:05 MST"))
+	fmt.Printf("🏛️  Issued by: %s (%s)\n", cert.Issuer, cert.ACMEServer)
+	if cert.MustStaple {
+		fmt.Printf("📌 OCSP Must-Staple: enabled, servers must staple OCSP responses\n")
+	} else if m.mustStaple {
+		fmt.Printf("⚠️  OCSP Must-Staple was requested but the certificate does not include it\n")
+	}
+	if root, err := acme.ParseChainRoot(paths.ChainFile); err == nil {
+		fmt.Printf("🔗 Chain root: %s\n", root)
+		if m.chain != "" && root != m.chain {
+			fmt.Printf("⚠️  Preferred chain %q was not offered by the CA, using the default chain\n", m.chain)
+		}
+	}
 
 	return nil
 }
 
+// obtainCertificate places the order with the configured CA and falls back to the
+// next configured CA when the order fails because a CA is unavailable or rate limited
+func (m *Manager) obtainCertificate(cfg *config.Config, domains []string, csr *x509.CertificateRequest, privateKey crypto.PrivateKey) (*acme.CertificateResult, error) {
+	cas := cfg.IssuanceCAs()
+
+	for i, ca := range cas {
+		if i > 0 {
+			fmt.Printf("🔁 Falling back to CA: %s\n", ca.Server)
+		}
+
+		// The replaced certificate is only known to the CA that issued it
+		opts := acme.ObtainOptions{
+			CSR:            csr,
+			PrivateKey:     privateKey,
+			PreferredChain: m.chain,
+			Profile:        m.profile,
+			MustStaple:     m.mustStaple,
+		}
+		if ca.Server == m.replacesCA {
+			opts.ReplacesCertID = m.replaces
+		}
+
+		cert, err := m.obtainFromCA(cfg.WithCA(ca), domains, opts)
+		if err == nil {
+			if i > 0 {
+				fmt.Printf("⚠️  Certificate was issued by fallback CA %s instead of %s\n", ca.Server, cas[0].Server)
+			}
+			return cert, nil
+		}
+
+		// An interrupted run is not a reason to try the next CA
+		if i == len(cas)-1 || m.ctx.Err() != nil || !acme.IsRetryableError(err, ca.Server) {
+			return nil, err
+		}
+
+		fmt.Printf("⚠️  Issuance via %s failed: %v\n", ca.Server, err)
+	}
+
+	return nil, fmt.Errorf("no certificate authority configured")
+}
+
+// obtainFromCA requests the certificate from a single CA
+func (m *Manager) obtainFromCA(cfg *config.Config, domains []string, opts acme.ObtainOptions) (*acme.CertificateResult, error) {
+	client, err := acme.NewClient(m.ctx, cfg, m.verbose, m.keyType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ACME client: %w", err)
+	}
+
+	return client.ObtainCertificate(domains, opts)
+}
+
 // CertificateAction represents the action to take for a certificate
 type CertificateAction int
 
@@ -155,6 +459,9 @@ func (m *Manager) determineAction(domains []string, paths utils.CertificatePaths
 		return ActionRenew, nil
 	}
 
+	// Renew once a third of the certificate lifetime remains
+	renewAt := expiresAt.Add(-acme.RenewalThresholdForFile(paths.CertFile, expiresAt))
+
 	// Check if domains match
 	if DomainsMatch(domains, existingDomains) {
 		now := time.Now()
@@ -166,7 +473,7 @@ func (m *Manager) determineAction(domains []string, paths utils.CertificatePaths
 				strings.Join(domains, ", "), -daysRemaining)
 			fmt.Printf("🔄 Automatically renewing expired certificate...\n")
 			return ActionRenew, nil
-		} else if daysRemaining <= 30 {
+		} else if now.After(renewAt) {
 			// Certificate expires soon
 			fmt.Printf("⚠️  Certificate for %s expires in %d days (%s)\n",
 				strings.Join(domains, ", "), daysRemaining, expiresAt.Format("2006-01-02 15:04"))
@@ -201,8 +508,67 @@ func (m *Manager) determineAction(domains []string, paths utils.CertificatePaths
 	}
 }
 
+// reusableKey returns the current private key when key reuse is enabled and the key is
+// still within the age limit, or nil when a fresh key should be generated
+func (m *Manager) reusableKey(paths utils.CertificatePaths, existing utils.CertificateMetadata, csr *x509.CertificateRequest) (crypto.PrivateKey, keyInfo) {
+	// Explicit settings win over the ones persisted with the certificate
+	key := keyInfo{
+		createdAt:     time.Now(),
+		reuse:         existing.ReuseKey,
+		maxKeyAgeDays: existing.MaxKeyAgeDays,
+	}
+	if m.reuseKeySet {
+		// Newly enabled reuse starts with the default age limit
+		if m.reuseKey && !existing.ReuseKey && existing.MaxKeyAgeDays == 0 {
+			key.maxKeyAgeDays = DefaultMaxKeyAgeDays
+		}
+		key.reuse = m.reuseKey
+	}
+	if m.maxKeyAgeDaysSet {
+		key.maxKeyAgeDays = m.maxKeyAgeDays
+	}
+
+	if !key.reuse || csr != nil {
+		return nil, key
+	}
+
+	privateKey, err := acme.LoadPrivateKey(paths.KeyFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			fmt.Printf("⚠️  Cannot reuse existing private key: %v\n", err)
+		}
+		return nil, key
+	}
+
+	var createdAt time.Time
+	if existing.KeyCreatedAt != nil {
+		createdAt = *existing.KeyCreatedAt
+	} else if info, err := os.Stat(paths.KeyFile); err == nil {
+		// Older metadata has no key creation time
+		createdAt = info.ModTime()
+	}
+
+	ageDays := int(time.Since(createdAt).Hours() / 24)
+	if key.maxKeyAgeDays > 0 && ageDays >= key.maxKeyAgeDays {
+		fmt.Printf("🔑 Private key is %d days old (limit %d days), generating a new key\n", ageDays, key.maxKeyAgeDays)
+		return nil, key
+	}
+
+	if keyType := acme.PublicKeyType(privateKey.Public()); keyType != m.keyType {
+		fmt.Printf("🔑 Existing private key is %s but %s was requested, generating a new key\n", keyType, m.keyType)
+		return nil, key
+	}
+
+	if m.verbose {
+		fmt.Printf("🔑 Reusing existing private key (%d days old)\n", ageDays)
+	}
+
+	key.createdAt = createdAt
+	return privateKey, key
+}
+
 // saveCertificateFiles saves all certificate files to disk
-func (m *Manager) saveCertificateFiles(cert *acme.CertificateResult, paths utils.CertificatePaths) error {
+func (m *Manager) saveCertificateFiles(cert *acme.CertificateResult, paths utils.CertificatePaths, csr *x509.CertificateRequest) error {
 	// Trim leading/trailing whitespace from IssuerCertificate and ensure proper formatting
 	trimmedIssuerCert := strings.TrimSpace(string(cert.IssuerCertificate))
 	if trimmedIssuerCert != "" && !strings.HasSuffix(trimmedIssuerCert, "\n") {
@@ -211,11 +577,18 @@ func (m *Manager) saveCertificateFiles(cert *acme.CertificateResult, paths utils
 
 	files := map[string][]byte{
 		paths.CertFile:      cert.Certificate,
-		paths.KeyFile:       cert.PrivateKey,
 		paths.ChainFile:     []byte(trimmedIssuerCert),
 		paths.FullchainFile: append(cert.Certificate, cert.IssuerCertificate...),
 	}
 
+	// Certificates issued from a CSR have no private key, keep the CSR for renewals instead
+	if len(cert.PrivateKey) > 0 {
+		files[paths.KeyFile] = cert.PrivateKey
+	}
+	if csr != nil {
+		files[paths.CSRFile] = acme.EncodeCSR(csr)
+	}
+
 	for filename, data := range files {
 		if err := os.WriteFile(filename, data, 0600); err != nil {
 			return fmt.Errorf("failed to save %s: %w", filename, err)
@@ -229,7 +602,7 @@ func (m *Manager) saveCertificateFiles(cert *acme.CertificateResult, paths utils
 }
 
 // saveCertificateMetadata saves certificate metadata to disk
-func (m *Manager) saveCertificateMetadata(domains []string, paths utils.CertificatePaths, cert *acme.CertificateResult) error {
+func (m *Manager) saveCertificateMetadata(domains []string, paths utils.CertificatePaths, cert *acme.CertificateResult, csr *x509.CertificateRequest, key keyInfo, zone string) error {
 	primaryDomain := domains[0]
 	isWildcard := false
 	for _, domain := range domains {
@@ -239,16 +612,34 @@ func (m *Manager) saveCertificateMetadata(domains []string, paths utils.Certific
 		}
 	}
 
+	keyType := m.keyType
+	if csr != nil {
+		keyType = acme.PublicKeyType(csr.PublicKey)
+	}
+
 	metadata := utils.CertificateMetadata{
-		Domain:       primaryDomain,
-		Domains:      domains,
-		IsWildcard:   isWildcard,
-		KeyType:      m.keyType,
-		CreatedAt:    time.Now(),
-		ExpiresAt:    cert.NotAfter,
-		ACMEServer:   m.config.ACMEServer,
-		Version:      "1.0",
-		RenewalCount: 0,
+		Domain:         primaryDomain,
+		Domains:        domains,
+		IsWildcard:     isWildcard,
+		KeyType:        keyType,
+		CreatedAt:      time.Now(),
+		ExpiresAt:      cert.NotAfter,
+		Issuer:         cert.Issuer,
+		ACMEServer:     cert.ACMEServer,
+		Version:        "1.0",
+		RenewalCount:   0,
+		FromCSR:        csr != nil,
+		PreferredChain: m.chain,
+		Profile:        m.profile,
+		MustStaple:     cert.MustStaple,
+		Zone:           zone,
+		ReuseKey:       key.reuse,
+		MaxKeyAgeDays:  key.maxKeyAgeDays,
+	}
+
+	// Certificates issued from a CSR have no private key of their own to age
+	if csr == nil {
+		metadata.KeyCreatedAt = &key.createdAt
 	}
 
 	return utils.SaveCertificateMetadata(paths.InfoFile, metadata)
@@ -0,0 +1,74 @@
+package certificate
+
+import (
+	"os"
+	"testing"
+
+	"github.com/bariiss/flarecert/internal/config"
+	"github.com/bariiss/flarecert/internal/utils"
+)
+
+func TestCheckKeyPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		minRSA  int
+		minEC   int
+		keyType string
+		wantErr bool
+	}{
+		{"no policy", 0, 0, "rsa2048", false},
+		{"rsa at minimum", 3072, 0, "rsa3072", false},
+		{"rsa above minimum", 3072, 0, "rsa8192", false},
+		{"rsa below minimum", 3072, 0, "rsa2048", true},
+		{"ec policy ignores rsa", 0, 384, "rsa2048", false},
+		{"ec at minimum", 0, 384, "ec384", false},
+		{"ec below minimum", 0, 384, "ec256", true},
+		{"rsa policy ignores ec", 4096, 0, "ec256", false},
+		{"unknown key type", 0, 0, "ecc256", true},
+	}
+
+	for _, tt := range tests {
+		m := &Manager{config: &config.Config{MinRSAKeySize: tt.minRSA, MinECKeySize: tt.minEC}}
+		if err := m.checkKeyPolicy(tt.keyType); (err != nil) != tt.wantErr {
+			t.Errorf("%s: checkKeyPolicy(%q) = %v, want error %v", tt.name, tt.keyType, err, tt.wantErr)
+		}
+	}
+}
+
+func TestConfigForZone(t *testing.T) {
+	certDir := t.TempDir()
+	domains := []string{"app.example.com"}
+
+	paths := utils.GetCertificatePathsForVariant(certDir, domains, "")
+	if err := os.MkdirAll(paths.CurrentDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := utils.SaveCertificateMetadata(paths.InfoFile, utils.CertificateMetadata{Domain: domains[0], Zone: "example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		zone    string
+		zoneSet bool
+		want    string
+	}{
+		{"persisted zone", "", false, "example.com"},
+		{"explicit zone", "zone-other", true, "zone-other"},
+		{"cleared zone", "", true, ""},
+	}
+
+	for _, tt := range tests {
+		m := &Manager{config: &config.Config{}, certDir: certDir}
+		if tt.zoneSet {
+			m.SetZone(tt.zone)
+		}
+
+		if got := m.configFor(domains).Zone; got != tt.want {
+			t.Errorf("%s: configFor().Zone = %q, want %q", tt.name, got, tt.want)
+		}
+		if m.config.Zone != "" {
+			t.Errorf("%s: configFor() changed the shared configuration to zone %q", tt.name, m.config.Zone)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Cloudflare authentication modes
+const (
+	// AuthToken uses one API token with Zone:Read and DNS:Edit (CLOUDFLARE_API_TOKEN)
+	AuthToken = "token"
+	// AuthGlobalKey uses the Global API Key of an account (CLOUDFLARE_API_KEY and CLOUDFLARE_EMAIL)
+	AuthGlobalKey = "global-key"
+	// AuthSplit uses a read-only token for zone discovery (CLOUDFLARE_ZONE_TOKEN) and a
+	// narrowly scoped token for record writes (CLOUDFLARE_DNS_TOKEN)
+	AuthSplit = "split"
+)
+
+// AuthModes lists the Cloudflare authentication modes selectable with CLOUDFLARE_AUTH
+var AuthModes = []string{AuthToken, AuthGlobalKey, AuthSplit}
+
+// DefaultAccount names the credentials given without an account name (CLOUDFLARE_API_TOKEN, ...)
+const DefaultAccount = "default"
+
+// CloudflareCredentials holds the API credentials of one Cloudflare account
+type CloudflareCredentials struct {
+	Name      string
+	Mode      string
+	APIToken  string
+	APIKey    string
+	Email     string
+	ZoneToken string
+	DNSToken  string
+}
+
+// CloudflareCredentials returns the credentials of every configured Cloudflare account,
+// the default account first
+func (c *Config) CloudflareCredentials() []CloudflareCredentials {
+	var accounts []CloudflareCredentials
+	if c.CloudflareAuth != "" {
+		accounts = append(accounts, CloudflareCredentials{
+			Name:      DefaultAccount,
+			Mode:      c.CloudflareAuth,
+			APIToken:  c.CloudflareAPIToken,
+			APIKey:    c.CloudflareAPIKey,
+			Email:     c.CloudflareEmail,
+			ZoneToken: c.CloudflareZoneToken,
+			DNSToken:  c.CloudflareDNSToken,
+		})
+	}
+	return append(accounts, c.CloudflareAccounts...)
+}
+
+// loadCloudflareAccounts reads the named accounts listed in CLOUDFLARE_ACCOUNTS from
+// CLOUDFLARE_<NAME>_API_TOKEN and friends, and the zone to account mapping
+func (c *Config) loadCloudflareAccounts() error {
+	if names := os.Getenv("CLOUDFLARE_ACCOUNTS"); names != "" {
+		for _, name := range strings.Split(names, ",") {
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name == "" {
+				continue
+			}
+			if name == DefaultAccount {
+				return fmt.Errorf("invalid CLOUDFLARE_ACCOUNTS: %q is reserved for the unnamed credentials", name)
+			}
+
+			prefix := accountPrefix(name)
+			c.CloudflareAccounts = append(c.CloudflareAccounts, CloudflareCredentials{
+				Name:      name,
+				Mode:      os.Getenv(prefix + "AUTH"),
+				APIToken:  os.Getenv(prefix + "API_TOKEN"),
+				APIKey:    os.Getenv(prefix + "API_KEY"),
+				Email:     os.Getenv(prefix + "EMAIL"),
+				ZoneToken: os.Getenv(prefix + "ZONE_TOKEN"),
+				DNSToken:  os.Getenv(prefix + "DNS_TOKEN"),
+			})
+		}
+	}
+
+	// Parse the zone to account mapping as zone=account pairs, the zone given by name or ID
+	if zoneAccounts := os.Getenv("CLOUDFLARE_ZONE_ACCOUNTS"); zoneAccounts != "" {
+		c.ZoneAccounts = make(map[string]string)
+		for _, pair := range strings.Split(zoneAccounts, ",") {
+			pair = strings.TrimSpace(pair)
+			if pair == "" {
+				continue
+			}
+
+			zone, account, ok := strings.Cut(pair, "=")
+			zone = strings.ToLower(strings.TrimSpace(zone))
+			account = strings.ToLower(strings.TrimSpace(account))
+			if !ok || zone == "" || account == "" {
+				return fmt.Errorf("invalid CLOUDFLARE_ZONE_ACCOUNTS entry %q, expected zone=account", pair)
+			}
+			c.ZoneAccounts[zone] = account
+		}
+	}
+
+	return nil
+}
+
+// accountPrefix returns the environment variable prefix of a named account
+func accountPrefix(name string) string {
+	return "CLOUDFLARE_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+}
+
+// AccountEnvPrefix returns the environment variable prefix of an account's credentials
+func AccountEnvPrefix(name string) string {
+	if name == DefaultAccount {
+		return "CLOUDFLARE_"
+	}
+	return accountPrefix(name)
+}
+
+// resolveCloudflareAuth selects the authentication mode of every account and checks that
+// its credentials are complete. The unnamed credentials may be left out when named
+// accounts are configured.
+func (c *Config) resolveCloudflareAuth() error {
+	defaultCreds := CloudflareCredentials{
+		Name:      DefaultAccount,
+		Mode:      c.CloudflareAuth,
+		APIToken:  c.CloudflareAPIToken,
+		APIKey:    c.CloudflareAPIKey,
+		Email:     c.CloudflareEmail,
+		ZoneToken: c.CloudflareZoneToken,
+		DNSToken:  c.CloudflareDNSToken,
+	}
+
+	hasDefault := defaultCreds.Mode != "" || defaultCreds.APIToken != "" || defaultCreds.APIKey != "" ||
+		defaultCreds.ZoneToken != "" || defaultCreds.DNSToken != ""
+	if hasDefault || len(c.CloudflareAccounts) == 0 {
+		if err := resolveCredentials(&defaultCreds, "CLOUDFLARE_"); err != nil {
+			return err
+		}
+		c.CloudflareAuth = defaultCreds.Mode
+	}
+
+	known := map[string]bool{DefaultAccount: hasDefault}
+	for i := range c.CloudflareAccounts {
+		account := &c.CloudflareAccounts[i]
+		if err := resolveCredentials(account, accountPrefix(account.Name)); err != nil {
+			return fmt.Errorf("cloudflare account %s: %w", account.Name, err)
+		}
+		known[account.Name] = true
+	}
+
+	for zone, account := range c.ZoneAccounts {
+		if !known[account] {
+			return fmt.Errorf("CLOUDFLARE_ZONE_ACCOUNTS maps %s to unknown account %q", zone, account)
+		}
+	}
+
+	return nil
+}
+
+// resolveCredentials selects the authentication mode from <prefix>AUTH, or from the
+// credentials that are set, and checks that the mode's credentials are complete
+func resolveCredentials(creds *CloudflareCredentials, prefix string) error {
+	mode := strings.ToLower(strings.TrimSpace(creds.Mode))
+
+	if mode == "" {
+		var modes []string
+		if creds.APIToken != "" {
+			modes = append(modes, AuthToken)
+		}
+		if creds.APIKey != "" {
+			modes = append(modes, AuthGlobalKey)
+		}
+		if creds.ZoneToken != "" || creds.DNSToken != "" {
+			modes = append(modes, AuthSplit)
+		}
+
+		switch len(modes) {
+		case 0:
+			return fmt.Errorf("cloudflare credentials are required: set %[1]sAPI_TOKEN, %[1]sAPI_KEY and %[1]sEMAIL, or %[1]sZONE_TOKEN and %[1]sDNS_TOKEN", prefix)
+		case 1:
+			mode = modes[0]
+		default:
+			return fmt.Errorf("credentials for several Cloudflare auth modes are set (%s), choose one with %sAUTH", strings.Join(modes, ", "), prefix)
+		}
+	}
+
+	switch mode {
+	case AuthToken:
+		if creds.APIToken == "" {
+			return fmt.Errorf("%sAPI_TOKEN is required", prefix)
+		}
+	case AuthGlobalKey:
+		if creds.APIKey == "" || creds.Email == "" {
+			return fmt.Errorf("%[1]sAPI_KEY and %[1]sEMAIL are required for the Global API Key", prefix)
+		}
+	case AuthSplit:
+		if creds.ZoneToken == "" || creds.DNSToken == "" {
+			return fmt.Errorf("%[1]sZONE_TOKEN and %[1]sDNS_TOKEN must be set together", prefix)
+		}
+	default:
+		return fmt.Errorf("invalid %sAUTH %q (valid: %s)", prefix, creds.Mode, strings.Join(AuthModes, ", "))
+	}
+
+	creds.Mode = mode
+	return nil
+}
@@ -0,0 +1,178 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResolveCredentials(t *testing.T) {
+	tests := []struct {
+		name    string
+		creds   CloudflareCredentials
+		want    string
+		wantErr string
+	}{
+		{"api token", CloudflareCredentials{APIToken: "token"}, AuthToken, ""},
+		{"global key", CloudflareCredentials{APIKey: "key", Email: "admin@example.com"}, AuthGlobalKey, ""},
+		{"split tokens", CloudflareCredentials{ZoneToken: "zone", DNSToken: "dns"}, AuthSplit, ""},
+		{"explicit mode", CloudflareCredentials{Mode: "Global-Key", APIToken: "token", APIKey: "key", Email: "admin@example.com"}, AuthGlobalKey, ""},
+		{"explicit token with other credentials", CloudflareCredentials{Mode: "token", APIToken: "token", ZoneToken: "zone"}, AuthToken, ""},
+		{"nothing set", CloudflareCredentials{}, "", "CLOUDFLARE_API_TOKEN"},
+		{"several modes", CloudflareCredentials{APIToken: "token", APIKey: "key", Email: "admin@example.com"}, "", "choose one with CLOUDFLARE_AUTH"},
+		{"global key without email", CloudflareCredentials{APIKey: "key"}, "", "CLOUDFLARE_EMAIL are required"},
+		{"zone token only", CloudflareCredentials{ZoneToken: "zone"}, "", "must be set together"},
+		{"dns token only", CloudflareCredentials{DNSToken: "dns"}, "", "must be set together"},
+		{"token mode without token", CloudflareCredentials{Mode: "token", APIKey: "key"}, "", "CLOUDFLARE_API_TOKEN is required"},
+		{"unknown mode", CloudflareCredentials{Mode: "oauth", APIToken: "token"}, "", "invalid CLOUDFLARE_AUTH"},
+	}
+
+	for _, tt := range tests {
+		creds := tt.creds
+		err := resolveCredentials(&creds, "CLOUDFLARE_")
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: resolveCredentials() = %v, want error containing %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || creds.Mode != tt.want {
+			t.Errorf("%s: resolveCredentials() = %v with mode %q, want mode %q", tt.name, err, creds.Mode, tt.want)
+		}
+	}
+}
+
+func TestLoadCloudflareAccounts(t *testing.T) {
+	t.Setenv("CLOUDFLARE_ACCOUNTS", " Prod, staging-eu ,")
+	t.Setenv("CLOUDFLARE_PROD_API_TOKEN", "prod-token")
+	t.Setenv("CLOUDFLARE_STAGING_EU_AUTH", "global-key")
+	t.Setenv("CLOUDFLARE_STAGING_EU_API_KEY", "staging-key")
+	t.Setenv("CLOUDFLARE_STAGING_EU_EMAIL", "admin@example.com")
+	t.Setenv("CLOUDFLARE_ZONE_ACCOUNTS", "Example.com=Prod, 023e105f4ecef8ad9ca31a8372d0c353 = staging-eu")
+
+	cfg := &Config{}
+	if err := cfg.loadCloudflareAccounts(); err != nil {
+		t.Fatalf("loadCloudflareAccounts() = %v", err)
+	}
+
+	want := []CloudflareCredentials{
+		{Name: "prod", APIToken: "prod-token"},
+		{Name: "staging-eu", Mode: "global-key", APIKey: "staging-key", Email: "admin@example.com"},
+	}
+	if len(cfg.CloudflareAccounts) != len(want) {
+		t.Fatalf("accounts = %+v, want %+v", cfg.CloudflareAccounts, want)
+	}
+	for i := range want {
+		if cfg.CloudflareAccounts[i] != want[i] {
+			t.Errorf("account %d = %+v, want %+v", i, cfg.CloudflareAccounts[i], want[i])
+		}
+	}
+
+	wantZones := map[string]string{"example.com": "prod", "023e105f4ecef8ad9ca31a8372d0c353": "staging-eu"}
+	if len(cfg.ZoneAccounts) != len(wantZones) {
+		t.Fatalf("zone accounts = %v, want %v", cfg.ZoneAccounts, wantZones)
+	}
+	for zone, account := range wantZones {
+		if cfg.ZoneAccounts[zone] != account {
+			t.Errorf("zone accounts[%q] = %q, want %q", zone, cfg.ZoneAccounts[zone], account)
+		}
+	}
+}
+
+func TestLoadCloudflareAccountsErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		accounts     string
+		zoneAccounts string
+		wantErr      string
+	}{
+		{"reserved name", "prod,Default", "", "reserved"},
+		{"zone without account", "prod", "example.com=", "expected zone=account"},
+		{"account without zone", "prod", "=prod", "expected zone=account"},
+		{"no separator", "prod", "example.com", "expected zone=account"},
+	}
+
+	for _, tt := range tests {
+		t.Setenv("CLOUDFLARE_ACCOUNTS", tt.accounts)
+		t.Setenv("CLOUDFLARE_ZONE_ACCOUNTS", tt.zoneAccounts)
+
+		cfg := &Config{}
+		if err := cfg.loadCloudflareAccounts(); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: loadCloudflareAccounts() = %v, want error containing %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestResolveCloudflareAuthAccounts(t *testing.T) {
+	prod := CloudflareCredentials{Name: "prod", APIToken: "prod-token"}
+
+	tests := []struct {
+		name         string
+		cfg          Config
+		wantAccounts []string
+		wantErr      string
+	}{
+		{
+			name:         "default account only",
+			cfg:          Config{CloudflareAPIToken: "token"},
+			wantAccounts: []string{DefaultAccount},
+		},
+		{
+			name:         "named accounts without default",
+			cfg:          Config{CloudflareAccounts: []CloudflareCredentials{prod}},
+			wantAccounts: []string{"prod"},
+		},
+		{
+			name:         "default account first",
+			cfg:          Config{CloudflareAPIToken: "token", CloudflareAccounts: []CloudflareCredentials{prod}},
+			wantAccounts: []string{DefaultAccount, "prod"},
+		},
+		{
+			name:         "zone mapped to default account",
+			cfg:          Config{CloudflareAPIToken: "token", CloudflareAccounts: []CloudflareCredentials{prod}, ZoneAccounts: map[string]string{"example.com": DefaultAccount}},
+			wantAccounts: []string{DefaultAccount, "prod"},
+		},
+		{
+			name:    "zone mapped to missing default account",
+			cfg:     Config{CloudflareAccounts: []CloudflareCredentials{prod}, ZoneAccounts: map[string]string{"example.com": DefaultAccount}},
+			wantErr: "unknown account",
+		},
+		{
+			name:    "zone mapped to unknown account",
+			cfg:     Config{CloudflareAPIToken: "token", ZoneAccounts: map[string]string{"example.com": "staging"}},
+			wantErr: "unknown account",
+		},
+		{
+			name:    "incomplete named account",
+			cfg:     Config{CloudflareAccounts: []CloudflareCredentials{{Name: "prod", ZoneToken: "zone"}}},
+			wantErr: "cloudflare account prod: CLOUDFLARE_PROD_ZONE_TOKEN",
+		},
+		{
+			name:    "no credentials",
+			cfg:     Config{},
+			wantErr: "credentials are required",
+		},
+	}
+
+	for _, tt := range tests {
+		cfg := tt.cfg
+		err := cfg.resolveCloudflareAuth()
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: resolveCloudflareAuth() = %v, want error containing %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: resolveCloudflareAuth() = %v", tt.name, err)
+			continue
+		}
+
+		var names []string
+		for _, creds := range cfg.CloudflareCredentials() {
+			names = append(names, creds.Name)
+		}
+		if strings.Join(names, ",") != strings.Join(tt.wantAccounts, ",") {
+			t.Errorf("%s: accounts = %v, want %v", tt.name, names, tt.wantAccounts)
+		}
+	}
+}
@@ -4,38 +4,259 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 )
 
+const (
+	// DefaultACMEServer is the Let's Encrypt production directory
+	DefaultACMEServer = "https://acme-v02.api.letsencrypt.org/directory"
+	// StagingACMEServer is the Let's Encrypt staging directory
+	StagingACMEServer = "https://acme-staging-v02.api.letsencrypt.org/directory"
+)
+
+// CAPreset describes a well-known ACME certificate authority
+type CAPreset struct {
+	Name          string
+	DirectoryURL  string
+	RequiresEAB   bool
+	CAAIdentities []string // Issuer domains that authorize the CA in CAA records
+}
+
+// CAPresets lists the certificate authorities selectable by name
+var CAPresets = []CAPreset{
+	{Name: "letsencrypt", DirectoryURL: DefaultACMEServer, CAAIdentities: []string{"letsencrypt.org"}},
+	{Name: "letsencrypt-staging", DirectoryURL: StagingACMEServer, CAAIdentities: []string{"letsencrypt.org"}},
+	{Name: "zerossl", DirectoryURL: "https://acme.zerossl.com/v2/DV90", RequiresEAB: true, CAAIdentities: []string{"sectigo.com", "zerossl.com"}},
+	{Name: "google", DirectoryURL: "https://dv.acme-v02.api.pki.goog/directory", RequiresEAB: true, CAAIdentities: []string{"pki.goog"}},
+	{Name: "buypass", DirectoryURL: "https://api.buypass.com/acme/directory", CAAIdentities: []string{"buypass.com", "buypass.no"}},
+}
+
+// CA holds the directory URL and External Account Binding credentials of a certificate authority
+type CA struct {
+	Server     string
+	EABKeyID   string
+	EABHMACKey string
+}
+
 // Config holds the application configuration
 type Config struct {
-	CloudflareAPIToken string
-	CloudflareEmail    string
-	ACMEEmail          string
-	ACMEServer         string
-	CertDir            string
-	DNSTimeout         int
+	CloudflareAuth      string
+	CloudflareAPIToken  string
+	CloudflareAPIKey    string
+	CloudflareEmail     string
+	CloudflareZoneToken string
+	CloudflareDNSToken  string
+	CloudflareAccounts  []CloudflareCredentials // Named accounts from CLOUDFLARE_ACCOUNTS
+	ZoneAccounts        map[string]string       // Account name by zone name or ID
+	ACMEEmail           string
+	ACMEServer          string
+	EABKeyID            string
+	EABHMACKey          string
+	FallbackCAs         []CA
+	CertDir             string
+	DNSTimeout          int
+	DNSInterval         int
+	DNSFallbackDelay    int
+	DNSResolvers        []string
+	DNSZoneConcurrency  int
+	ZoneCacheTTL        int
+	CloudflareRetries   int
+	MinRSAKeySize       int
+	MinECKeySize        int
+	ChallengeAliases    map[string]string
+	Zone                string
+	ZoneMap             map[string]string
+	CAAIdentities       []string // CAA issuer domains of CAAServer, for CAs without a preset
+	CAAServer           string   // ACME server from the environment CAAIdentities belong to
+
+	envEAB CA // ACME_EAB_KID and ACME_EAB_HMAC with the ACME server from the environment
+}
+
+// CAPresetNames returns the names of all CA presets
+func CAPresetNames() []string {
+	names := make([]string, 0, len(CAPresets))
+	for _, preset := range CAPresets {
+		names = append(names, preset.Name)
+	}
+	return names
+}
+
+// ResolveCA returns the directory URL for a CA preset name or a directory URL
+func ResolveCA(ca string) (string, error) {
+	if strings.HasPrefix(ca, "https://") || strings.HasPrefix(ca, "http://") {
+		return ca, nil
+	}
+
+	for _, preset := range CAPresets {
+		if preset.Name == ca {
+			return preset.DirectoryURL, nil
+		}
+	}
+
+	return "", fmt.Errorf("unknown CA %q (valid: %s, or a directory URL)", ca, strings.Join(CAPresetNames(), ", "))
+}
+
+// IssuanceCAs returns the configured CA followed by the fallback CAs, in the order they are tried
+func (c *Config) IssuanceCAs() []CA {
+	cas := []CA{{Server: c.ACMEServer, EABKeyID: c.EABKeyID, EABHMACKey: c.EABHMACKey}}
+	for _, fallback := range c.FallbackCAs {
+		if fallback.Server != c.ACMEServer {
+			cas = append(cas, fallback)
+		}
+	}
+	return cas
+}
+
+// IsIssuanceCA reports whether server is the configured CA or one of the fallback CAs
+func (c *Config) IsIssuanceCA(server string) bool {
+	for _, ca := range c.IssuanceCAs() {
+		if ca.Server == server {
+			return true
+		}
+	}
+	return false
+}
+
+// WithCA returns a copy of the configuration that issues from the given CA
+func (c *Config) WithCA(ca CA) *Config {
+	clone := *c
+	clone.ACMEServer = ca.Server
+	clone.EABKeyID = ca.EABKeyID
+	clone.EABHMACKey = ca.EABHMACKey
+	return &clone
+}
+
+// SelectCA overrides the ACME server from a --ca value or the --staging flag
+func (c *Config) SelectCA(ca string, staging bool) error {
+	if ca != "" && staging {
+		return fmt.Errorf("cannot use both --ca and --staging flags together")
+	}
+
+	if staging {
+		c.ACMEServer = StagingACMEServer
+	} else if ca != "" {
+		server, err := ResolveCA(ca)
+		if err != nil {
+			return err
+		}
+		c.ACMEServer = server
+	}
+
+	c.selectEAB()
+	return nil
+}
+
+// selectEAB uses the EAB credentials configured for the selected ACME server. Credentials of
+// a fallback CA come from ACME_EAB_KID_<NAME> / ACME_EAB_HMAC_<NAME>, ACME_EAB_KID and
+// ACME_EAB_HMAC only belong to the ACME server from the environment.
+func (c *Config) selectEAB() {
+	for _, fallback := range c.FallbackCAs {
+		if fallback.Server == c.ACMEServer && fallback.EABKeyID != "" {
+			c.EABKeyID = fallback.EABKeyID
+			c.EABHMACKey = fallback.EABHMACKey
+			return
+		}
+	}
+
+	if c.envEAB.Server == c.ACMEServer {
+		c.EABKeyID = c.envEAB.EABKeyID
+		c.EABHMACKey = c.envEAB.EABHMACKey
+		return
+	}
+
+	c.EABKeyID = ""
+	c.EABHMACKey = ""
+}
+
+// CAAIdentitiesFor returns the issuer domains that authorize the CA behind server in CAA
+// records, nil when they are unknown
+func (c *Config) CAAIdentitiesFor(server string) []string {
+	for _, preset := range CAPresets {
+		if preset.DirectoryURL == server {
+			return preset.CAAIdentities
+		}
+	}
+	if server == c.CAAServer {
+		return c.CAAIdentities
+	}
+	return nil
+}
+
+// RequiresEAB reports whether the configured ACME server is known to require External Account Binding
+func (c *Config) RequiresEAB() bool {
+	for _, preset := range CAPresets {
+		if preset.DirectoryURL == c.ACMEServer {
+			return preset.RequiresEAB
+		}
+	}
+	return false
 }
 
 // Load loads configuration from environment variables
 func Load() (*Config, error) {
 	cfg := &Config{
-		CloudflareAPIToken: os.Getenv("CLOUDFLARE_API_TOKEN"),
-		CloudflareEmail:    os.Getenv("CLOUDFLARE_EMAIL"),
-		ACMEEmail:          os.Getenv("ACME_EMAIL"),
-		ACMEServer:         os.Getenv("ACME_SERVER"),
-		CertDir:            os.Getenv("CERT_DIR"),
-		DNSTimeout:         300, // default 5 minutes
+		CloudflareAuth:      os.Getenv("CLOUDFLARE_AUTH"),
+		CloudflareAPIToken:  os.Getenv("CLOUDFLARE_API_TOKEN"),
+		CloudflareAPIKey:    os.Getenv("CLOUDFLARE_API_KEY"),
+		CloudflareEmail:     os.Getenv("CLOUDFLARE_EMAIL"),
+		CloudflareZoneToken: os.Getenv("CLOUDFLARE_ZONE_TOKEN"),
+		CloudflareDNSToken:  os.Getenv("CLOUDFLARE_DNS_TOKEN"),
+		ACMEEmail:           os.Getenv("ACME_EMAIL"),
+		ACMEServer:          os.Getenv("ACME_SERVER"),
+		EABKeyID:            os.Getenv("ACME_EAB_KID"),
+		EABHMACKey:          os.Getenv("ACME_EAB_HMAC"),
+		CertDir:             os.Getenv("CERT_DIR"),
+		DNSTimeout:          300, // default 5 minutes
+		DNSInterval:         10,
+		DNSFallbackDelay:    30,
+		DNSZoneConcurrency:  4,
+		ZoneCacheTTL:        3600, // default 1 hour
+		CloudflareRetries:   5,
+	}
+
+	// A named CA preset takes precedence over a raw directory URL
+	if ca := os.Getenv("ACME_CA"); ca != "" {
+		server, err := ResolveCA(ca)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ACME_CA: %w", err)
+		}
+		cfg.ACMEServer = server
 	}
 
 	// Set defaults
 	if cfg.ACMEServer == "" {
-		cfg.ACMEServer = "https://acme-v02.api.letsencrypt.org/directory"
+		cfg.ACMEServer = DefaultACMEServer
 	}
 
 	if cfg.CertDir == "" {
 		cfg.CertDir = "./certs"
 	}
 
+	cfg.envEAB = CA{Server: cfg.ACMEServer, EABKeyID: cfg.EABKeyID, EABHMACKey: cfg.EABHMACKey}
+
+	// Parse fallback CAs, EAB credentials are read from ACME_EAB_KID_<NAME> and ACME_EAB_HMAC_<NAME>
+	if fallbacks := os.Getenv("ACME_FALLBACK_CAS"); fallbacks != "" {
+		for _, name := range strings.Split(fallbacks, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+
+			server, err := ResolveCA(name)
+			if err != nil {
+				return nil, fmt.Errorf("invalid ACME_FALLBACK_CAS: %w", err)
+			}
+
+			suffix := strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+			cfg.FallbackCAs = append(cfg.FallbackCAs, CA{
+				Server:     server,
+				EABKeyID:   os.Getenv("ACME_EAB_KID_" + suffix),
+				EABHMACKey: os.Getenv("ACME_EAB_HMAC_" + suffix),
+			})
+		}
+		cfg.selectEAB()
+	}
+
 	// Parse DNS timeout
 	if timeoutStr := os.Getenv("DNS_PROPAGATION_TIMEOUT"); timeoutStr != "" {
 		if timeout, err := strconv.Atoi(timeoutStr); err == nil && timeout > 0 {
@@ -43,30 +264,132 @@ func Load() (*Config, error) {
 		}
 	}
 
-	// Validate required fields
-	if cfg.CloudflareAPIToken == "" {
-		return nil, fmt.Errorf("CLOUDFLARE_API_TOKEN is required")
+	// Parse DNS propagation check interval and the fixed delay used when no nameserver can be queried
+	if intervalStr := os.Getenv("DNS_PROPAGATION_INTERVAL"); intervalStr != "" {
+		if interval, err := strconv.Atoi(intervalStr); err == nil && interval > 0 {
+			cfg.DNSInterval = interval
+		}
 	}
 
-	if cfg.CloudflareEmail == "" {
-		return nil, fmt.Errorf("CLOUDFLARE_EMAIL is required")
+	if delayStr := os.Getenv("DNS_PROPAGATION_FALLBACK_DELAY"); delayStr != "" {
+		if delay, err := strconv.Atoi(delayStr); err == nil && delay >= 0 {
+			cfg.DNSFallbackDelay = delay
+		}
+	}
+
+	// Parse how long the zone list is cached on disk, 0 disables the on-disk cache
+	if ttlStr := os.Getenv("ZONE_CACHE_TTL"); ttlStr != "" {
+		if ttl, err := strconv.Atoi(ttlStr); err == nil && ttl >= 0 {
+			cfg.ZoneCacheTTL = ttl
+		}
+	}
+
+	// Parse how often transient Cloudflare API failures (network errors, 429, 5xx) are retried
+	if retriesStr := os.Getenv("CLOUDFLARE_MAX_RETRIES"); retriesStr != "" {
+		if retries, err := strconv.Atoi(retriesStr); err == nil && retries >= 0 {
+			cfg.CloudflareRetries = retries
+		}
+	}
+
+	// Parse how many challenge records are created in parallel per zone
+	if concurrencyStr := os.Getenv("DNS_ZONE_CONCURRENCY"); concurrencyStr != "" {
+		if concurrency, err := strconv.Atoi(concurrencyStr); err == nil && concurrency > 0 {
+			cfg.DNSZoneConcurrency = concurrency
+		}
+	}
+
+	// Additional resolvers that must see the challenge record, e.g. 1.1.1.1,8.8.8.8:53
+	if resolvers := os.Getenv("DNS_RESOLVERS"); resolvers != "" {
+		for _, resolver := range strings.Split(resolvers, ",") {
+			if resolver = strings.TrimSpace(resolver); resolver != "" {
+				cfg.DNSResolvers = append(cfg.DNSResolvers, resolver)
+			}
+		}
+	}
+
+	// CAA issuer domains of a CA given by directory URL, e.g. ACME_CAA_IDENTITY=ca.example.net
+	if identities := os.Getenv("ACME_CAA_IDENTITY"); identities != "" {
+		for _, identity := range strings.Split(identities, ",") {
+			if identity = strings.ToLower(strings.TrimSpace(identity)); identity != "" {
+				cfg.CAAIdentities = append(cfg.CAAIdentities, identity)
+			}
+		}
+		cfg.CAAServer = cfg.ACMEServer
+	}
+
+	// Parse the zone map as suffix=zoneID pairs, used before automatic zone detection
+	if zoneMap := os.Getenv("CLOUDFLARE_ZONE_MAP"); zoneMap != "" {
+		cfg.ZoneMap = make(map[string]string)
+		for _, pair := range strings.Split(zoneMap, ",") {
+			pair = strings.TrimSpace(pair)
+			if pair == "" {
+				continue
+			}
+
+			suffix, zoneID, ok := strings.Cut(pair, "=")
+			suffix = strings.ToLower(strings.TrimSpace(suffix))
+			zoneID = strings.TrimSpace(zoneID)
+			if !ok || suffix == "" || zoneID == "" {
+				return nil, fmt.Errorf("invalid CLOUDFLARE_ZONE_MAP entry %q, expected suffix=zoneID", pair)
+			}
+			cfg.ZoneMap[suffix] = zoneID
+		}
+	}
+
+	// Parse challenge delegations as domain=target pairs, the target being the FQDN _acme-challenge.<domain> is CNAMEd to
+	if aliases := os.Getenv("ACME_CHALLENGE_ALIASES"); aliases != "" {
+		cfg.ChallengeAliases = make(map[string]string)
+		for _, pair := range strings.Split(aliases, ",") {
+			pair = strings.TrimSpace(pair)
+			if pair == "" {
+				continue
+			}
+
+			domain, target, ok := strings.Cut(pair, "=")
+			domain = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(domain), "*."))
+			target = strings.ToLower(strings.TrimSpace(target))
+			if !ok || domain == "" || target == "" {
+				return nil, fmt.Errorf("invalid ACME_CHALLENGE_ALIASES entry %q, expected domain=target", pair)
+			}
+			cfg.ChallengeAliases[domain] = target
+		}
+	}
+
+	// Parse the minimum key policy, 0 allows every supported size
+	for env, size := range map[string]*int{"MIN_RSA_KEY_SIZE": &cfg.MinRSAKeySize, "MIN_EC_KEY_SIZE": &cfg.MinECKeySize} {
+		if value := os.Getenv(env); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed < 0 {
+				return nil, fmt.Errorf("invalid %s: %q is not a key size in bits", env, value)
+			}
+			*size = parsed
+		}
+	}
+
+	if err := cfg.loadCloudflareAccounts(); err != nil {
+		return nil, err
+	}
+
+	// Validate required fields
+	if err := cfg.resolveCloudflareAuth(); err != nil {
+		return nil, err
 	}
 
 	if cfg.ACMEEmail == "" {
 		return nil, fmt.Errorf("ACME_EMAIL is required")
 	}
 
+	if (cfg.EABKeyID == "") != (cfg.EABHMACKey == "") {
+		return nil, fmt.Errorf("ACME_EAB_KID and ACME_EAB_HMAC must be set together")
+	}
+
 	return cfg, nil
 }
 
 // Validate checks if the configuration is valid
 func (c *Config) Validate() error {
-	if c.CloudflareAPIToken == "" {
-		return fmt.Errorf("cloudflare API token is required")
-	}
-
-	if c.CloudflareEmail == "" {
-		return fmt.Errorf("cloudflare email is required")
+	if err := c.resolveCloudflareAuth(); err != nil {
+		return err
 	}
 
 	if c.ACMEEmail == "" {
@@ -77,5 +400,9 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("ACME server URL is required")
 	}
 
+	if c.RequiresEAB() && c.EABKeyID == "" {
+		return fmt.Errorf("ACME server %s requires External Account Binding (ACME_EAB_KID and ACME_EAB_HMAC)", c.ACMEServer)
+	}
+
 	return nil
 }
@@ -0,0 +1,53 @@
+package config
+
+import "testing"
+
+func TestSelectCAEAB(t *testing.T) {
+	zerossl, _ := ResolveCA("zerossl")
+	google, _ := ResolveCA("google")
+
+	tests := []struct {
+		name     string
+		ca       string
+		staging  bool
+		server   string
+		wantKID  string
+		wantHMAC string
+	}{
+		{"configured server", "", false, zerossl, "zerossl-kid", "zerossl-hmac"},
+		{"same server by preset", "zerossl", false, zerossl, "zerossl-kid", "zerossl-hmac"},
+		{"staging", "", true, StagingACMEServer, "", ""},
+		{"other preset", "letsencrypt", false, DefaultACMEServer, "", ""},
+		{"other directory URL", "https://acme.example.com/directory", false, "https://acme.example.com/directory", "", ""},
+		{"fallback CA", "google", false, google, "google-kid", "google-hmac"},
+	}
+
+	for _, tt := range tests {
+		t.Setenv("ACME_EMAIL", "admin@example.com")
+		t.Setenv("CLOUDFLARE_API_TOKEN", "token")
+		t.Setenv("ACME_SERVER", zerossl)
+		t.Setenv("ACME_EAB_KID", "zerossl-kid")
+		t.Setenv("ACME_EAB_HMAC", "zerossl-hmac")
+		t.Setenv("ACME_FALLBACK_CAS", "google")
+		t.Setenv("ACME_EAB_KID_GOOGLE", "google-kid")
+		t.Setenv("ACME_EAB_HMAC_GOOGLE", "google-hmac")
+
+		cfg, err := Load()
+		if err != nil {
+			t.Fatalf("Load() = %v", err)
+		}
+
+		if err := cfg.SelectCA(tt.ca, tt.staging); err != nil {
+			t.Fatalf("%s: SelectCA() = %v", tt.name, err)
+		}
+
+		if cfg.ACMEServer != tt.server || cfg.EABKeyID != tt.wantKID || cfg.EABHMACKey != tt.wantHMAC {
+			t.Errorf("%s: SelectCA() selected %s with EAB %q/%q, want %s with %q/%q",
+				tt.name, cfg.ACMEServer, cfg.EABKeyID, cfg.EABHMACKey, tt.server, tt.wantKID, tt.wantHMAC)
+		}
+
+		if primary := cfg.IssuanceCAs()[0]; primary.EABKeyID != tt.wantKID {
+			t.Errorf("%s: IssuanceCAs()[0] has EAB key ID %q, want %q", tt.name, primary.EABKeyID, tt.wantKID)
+		}
+	}
+}
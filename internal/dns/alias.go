@@ -0,0 +1,41 @@
+package dns
+
+import (
+	"log"
+	"strings"
+
+	"github.com/go-acme/lego/v4/challenge/dns01"
+)
+
+// SetChallengeAliases sets explicit challenge delegations, mapping a domain to the
+// FQDN its _acme-challenge record is CNAMEd to
+func (p *CloudflareProvider) SetChallengeAliases(aliases map[string]string) {
+	p.aliases = aliases
+}
+
+// challengeFQDN returns the FQDN the challenge TXT record for domain is created at.
+// A configured alias wins, otherwise an existing _acme-challenge CNAME is followed.
+func (p *CloudflareProvider) challengeFQDN(domain string) (string, bool) {
+	domain = strings.ToLower(strings.TrimPrefix(domain, "*."))
+
+	if target, ok := p.aliases[domain]; ok {
+		return dns01.ToFqdn(target), true
+	}
+
+	info := dns01.GetChallengeInfo(domain, "")
+	return info.EffectiveFQDN, info.EffectiveFQDN != info.FQDN
+}
+
+// challengeZoneDomain returns the name the zone of the challenge record for domain is looked up by.
+// Delegated records live in the target zone, all others in the domain's own zone.
+func (p *CloudflareProvider) challengeZoneDomain(domain string) string {
+	fqdn, aliased := p.challengeFQDN(domain)
+	if !aliased {
+		return domain
+	}
+
+	if p.verbose {
+		log.Printf("Challenge for %s is delegated to %s", domain, fqdn)
+	}
+	return dns01.UnFqdn(fqdn)
+}
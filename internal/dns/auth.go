@@ -0,0 +1,139 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/bariiss/flarecert/internal/config"
+	"github.com/cloudflare/cloudflare-go"
+)
+
+// account holds the API clients of one Cloudflare account
+type account struct {
+	name       string
+	mode       string
+	client     *cloudflare.API // Record reads and writes
+	zoneClient *cloudflare.API // Zone discovery, the same client unless tokens are split
+	tokens     []TokenInfo
+}
+
+// AccountInfo describes a configured Cloudflare account
+type AccountInfo struct {
+	Name   string
+	Mode   string      // token, global-key or split
+	Tokens []TokenInfo // As reported when verified, none for the Global API Key
+}
+
+// newAccount creates the API clients of an account and verifies its credentials
+func newAccount(ctx context.Context, creds config.CloudflareCredentials, transport *retryTransport) (*account, error) {
+	// Retries are handled by the transport, which honours Retry-After
+	opts := []cloudflare.Option{
+		cloudflare.HTTPClient(&http.Client{Transport: transport}),
+		cloudflare.UsingRetryPolicy(0, 0, 0),
+	}
+
+	acc := &account{name: creds.Name, mode: creds.Mode}
+
+	switch creds.Mode {
+	case config.AuthGlobalKey:
+		api, err := cloudflare.New(creds.APIKey, creds.Email, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Cloudflare client: %w", err)
+		}
+
+		// The Global API Key has no token to verify, reading the user proves it works
+		if _, err := api.UserDetails(ctx); err != nil {
+			return nil, fmt.Errorf("invalid Cloudflare Global API Key for %s: %w", creds.Email, err)
+		}
+		acc.client, acc.zoneClient = api, api
+
+	case config.AuthSplit:
+		zoneClient, zoneToken, err := newTokenClient(ctx, "zone token", creds.ZoneToken, opts)
+		if err != nil {
+			return nil, err
+		}
+		dnsClient, dnsToken, err := newTokenClient(ctx, "DNS token", creds.DNSToken, opts)
+		if err != nil {
+			return nil, err
+		}
+		acc.client, acc.zoneClient = dnsClient, zoneClient
+		acc.tokens = []TokenInfo{zoneToken, dnsToken}
+
+	default:
+		api, token, err := newTokenClient(ctx, "API token", creds.APIToken, opts)
+		if err != nil {
+			return nil, err
+		}
+		acc.client, acc.zoneClient = api, api
+		acc.tokens = []TokenInfo{token}
+	}
+
+	return acc, nil
+}
+
+// newTokenClient creates a Cloudflare API client for an API token and verifies the token
+func newTokenClient(ctx context.Context, name, apiToken string, opts []cloudflare.Option) (*cloudflare.API, TokenInfo, error) {
+	api, err := cloudflare.NewWithAPIToken(apiToken, opts...)
+	if err != nil {
+		return nil, TokenInfo{}, fmt.Errorf("failed to create Cloudflare client: %w", err)
+	}
+
+	token, err := api.VerifyAPIToken(ctx)
+	if err != nil {
+		return nil, TokenInfo{}, fmt.Errorf("invalid Cloudflare %s: %w", name, err)
+	}
+
+	return api, TokenInfo{
+		Name:      name,
+		ID:        token.ID,
+		Status:    token.Status,
+		ExpiresOn: token.ExpiresOn,
+	}, nil
+}
+
+// SetZoneAccounts pins zones, given by name or ID, to the account managing them
+func (p *CloudflareProvider) SetZoneAccounts(zoneAccounts map[string]string) {
+	p.zoneAccounts = zoneAccounts
+}
+
+// Accounts returns the configured Cloudflare accounts
+func (p *CloudflareProvider) Accounts() []AccountInfo {
+	infos := make([]AccountInfo, 0, len(p.accounts))
+	for _, acc := range p.accounts {
+		infos = append(infos, AccountInfo{Name: acc.name, Mode: acc.mode, Tokens: acc.tokens})
+	}
+	return infos
+}
+
+// accountNamed returns the account with the given name, the first account when unknown
+func (p *CloudflareProvider) accountNamed(name string) *account {
+	for _, acc := range p.accounts {
+		if acc.name == name {
+			return acc
+		}
+	}
+	return p.accounts[0]
+}
+
+// accountFor returns the account managing a zone: the pinned account, the account the
+// zone was listed by, or the first account for zones no account can list
+func (p *CloudflareProvider) accountFor(zoneID string) *account {
+	if name, ok := p.zoneAccounts[strings.ToLower(zoneID)]; ok {
+		return p.accountNamed(name)
+	}
+
+	if zones, err := p.ListZones(); err == nil {
+		for _, zone := range zones {
+			if zone.ID == zoneID {
+				if pinned := p.pinnedAccount(zone); pinned != "" {
+					return p.accountNamed(pinned)
+				}
+				return p.accountNamed(zone.Account)
+			}
+		}
+	}
+
+	return p.accounts[0]
+}
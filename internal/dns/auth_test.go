@@ -0,0 +1,42 @@
+package dns
+
+import "testing"
+
+func TestAccountFor(t *testing.T) {
+	defaultAccount := &account{name: "default"}
+	prod := &account{name: "prod"}
+
+	p := &CloudflareProvider{
+		accounts: []*account{defaultAccount, prod},
+		zoneAccounts: map[string]string{
+			"zone-pinned":    "prod",
+			"pinned.example": "prod",
+			"zone-removed":   "removed",
+		},
+	}
+	p.cache.zones = []ZoneInfo{
+		{ID: "zone-listed", Name: "listed.example", Account: "prod"},
+		{ID: "zone-by-name", Name: "pinned.example", Account: "default"},
+		{ID: "zone-default", Name: "default.example", Account: "default"},
+	}
+	p.cache.fetched = true
+
+	tests := []struct {
+		zoneID string
+		want   *account
+	}{
+		{"zone-pinned", prod},
+		{"ZONE-PINNED", prod},
+		{"zone-listed", prod},
+		{"zone-by-name", prod},
+		{"zone-default", defaultAccount},
+		{"zone-unknown", defaultAccount},
+		{"zone-removed", defaultAccount},
+	}
+
+	for _, tt := range tests {
+		if got := p.accountFor(tt.zoneID); got != tt.want {
+			t.Errorf("accountFor(%q) = %s, want %s", tt.zoneID, got.name, tt.want.name)
+		}
+	}
+}
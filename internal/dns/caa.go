@@ -0,0 +1,379 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/cloudflare/cloudflare-go"
+	"github.com/go-acme/lego/v4/challenge/dns01"
+	"github.com/miekg/dns"
+)
+
+// defaultCAAResolvers are queried when neither DNS_RESOLVERS nor /etc/resolv.conf name a resolver
+var defaultCAAResolvers = []string{"1.1.1.1:53", "8.8.8.8:53"}
+
+// CAARecord is a single CAA property
+type CAARecord struct {
+	Flag  uint8
+	Tag   string
+	Value string
+}
+
+// CAASet is the CAA record set relevant for a domain, found at Owner while climbing the tree.
+// An empty set means no CAA records exist and any CA may issue.
+type CAASet struct {
+	Owner   string
+	Records []CAARecord
+}
+
+// LookupCAA finds the relevant CAA record set of a domain (RFC 8659): the records of the
+// domain itself, or of the closest parent that has any. Wildcards are looked up at their base.
+func LookupCAA(ctx context.Context, domain string, resolvers []string) (CAASet, error) {
+	name := strings.ToLower(strings.TrimSuffix(strings.TrimPrefix(domain, "*."), "."))
+
+	nameservers := caaNameservers(resolvers)
+	for name != "" && strings.Contains(name, ".") {
+		records, err := lookupCAARecords(ctx, name, nameservers)
+		if err != nil {
+			return CAASet{}, fmt.Errorf("failed to look up CAA records of %s: %w", name, err)
+		}
+		if len(records) > 0 {
+			return CAASet{Owner: name, Records: records}, nil
+		}
+
+		_, name, _ = strings.Cut(name, ".")
+	}
+
+	return CAASet{}, nil
+}
+
+// caaNameservers returns the resolvers CAA records are looked up with
+func caaNameservers(resolvers []string) []string {
+	if len(resolvers) > 0 {
+		return resolvers
+	}
+
+	if conf, err := dns.ClientConfigFromFile("/etc/resolv.conf"); err == nil && len(conf.Servers) > 0 {
+		servers := make([]string, 0, len(conf.Servers))
+		for _, server := range conf.Servers {
+			servers = append(servers, net.JoinHostPort(server, conf.Port))
+		}
+		return servers
+	}
+
+	return defaultCAAResolvers
+}
+
+// lookupCAARecords queries the CAA records at name, trying each nameserver until one answers
+func lookupCAARecords(ctx context.Context, name string, nameservers []string) ([]CAARecord, error) {
+	var lastErr error
+	for _, nameserver := range nameservers {
+		if _, _, err := net.SplitHostPort(nameserver); err != nil {
+			nameserver = net.JoinHostPort(nameserver, "53")
+		}
+
+		msg := new(dns.Msg)
+		msg.SetQuestion(dns01.ToFqdn(name), dns.TypeCAA)
+		msg.RecursionDesired = true
+
+		client := &dns.Client{Timeout: 5 * time.Second}
+		resp, _, err := client.ExchangeContext(ctx, msg, nameserver)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		// CAs refuse to issue when the lookup fails, so SERVFAIL is not treated as "no records"
+		if resp.Rcode != dns.RcodeSuccess && resp.Rcode != dns.RcodeNameError {
+			lastErr = fmt.Errorf("unexpected response code %s from %s", dns.RcodeToString[resp.Rcode], nameserver)
+			continue
+		}
+
+		// A CNAME is followed by the resolver, its target's CAA records are in the answer
+		var records []CAARecord
+		for _, rr := range resp.Answer {
+			if caa, ok := rr.(*dns.CAA); ok {
+				records = append(records, CAARecord{Flag: caa.Flag, Tag: caa.Tag, Value: caa.Value})
+			}
+		}
+		return records, nil
+	}
+
+	return nil, lastErr
+}
+
+// Permits reports whether a CA known by one of identities may issue for the domain, with
+// accountURI the ACME account the order is placed with. Wildcard certificates are governed
+// by issuewild properties when there are any. The error explains why issuance is refused.
+func (s CAASet) Permits(identities []string, accountURI string, wildcard bool) error {
+	var issue, issueWild []CAARecord
+	for _, record := range s.Records {
+		switch strings.ToLower(record.Tag) {
+		case "issue":
+			issue = append(issue, record)
+		case "issuewild":
+			issueWild = append(issueWild, record)
+		case "iodef", "contactemail", "contactphone", "issuemail", "issuevmc":
+		default:
+			// CAs must refuse to issue when they do not understand a critical property
+			if record.Flag&128 != 0 {
+				return fmt.Errorf("CAA record at %s has the unknown critical property %q", s.Owner, record.Tag)
+			}
+		}
+	}
+
+	tag, records := "issue", issue
+	if wildcard && len(issueWild) > 0 {
+		tag, records = "issuewild", issueWild
+	}
+	if len(records) == 0 {
+		return nil
+	}
+
+	var allowed []string
+	for _, record := range records {
+		allowed = append(allowed, fmt.Sprintf("%q", record.Value))
+
+		issuer, params := ParseCAAValue(record.Value)
+		if issuer == "" || !containsFold(identities, issuer) {
+			continue
+		}
+		if uri, ok := params["accounturi"]; ok && uri != accountURI {
+			continue
+		}
+		if methods, ok := params["validationmethods"]; ok && !containsFold(strings.Split(methods, ","), "dns-01") {
+			continue
+		}
+		return nil
+	}
+
+	return fmt.Errorf("CAA %s records at %s do not allow %s to issue (found %s)",
+		tag, s.Owner, strings.Join(identities, " or "), strings.Join(allowed, ", "))
+}
+
+// ParseCAAValue splits an issue or issuewild value into the issuer domain and its parameters
+func ParseCAAValue(value string) (string, map[string]string) {
+	parts := strings.Split(value, ";")
+	issuer := strings.ToLower(strings.TrimSpace(parts[0]))
+
+	params := make(map[string]string)
+	for _, part := range parts[1:] {
+		key, val, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if ok {
+			params[strings.ToLower(strings.TrimSpace(key))] = strings.TrimSpace(val)
+		}
+	}
+
+	return issuer, params
+}
+
+// containsFold reports whether values contains value, ignoring case
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(strings.TrimSpace(v), value) {
+			return true
+		}
+	}
+	return false
+}
+
+// CAAChange is a CAA record to create or update in Cloudflare
+type CAAChange struct {
+	Name     string
+	Flag     uint8
+	Tag      string
+	Value    string
+	Previous string // Value of the record being replaced, empty for new records
+	Action   string // "create", "update" or "none" when the record is already in place
+	Reason   string // Why a record of another CA is written, empty for the CA's own records
+
+	zoneID   string
+	recordID string
+	tags     []string
+}
+
+// caaEntry is a CAA record at the planned name, with its Cloudflare record when it exists
+type caaEntry struct {
+	CAARecord
+	recordID string
+	tags     []string
+}
+
+// PlanCAA works out the CAA records to write at name so issuer may issue with value for each
+// tag (issue, issuewild). inherited is the CAA set the name resolves to (LookupCAA). Records
+// of other CAs are left alone, and records that would otherwise stop applying are copied so
+// CAs that may issue today still may. The warnings name issuance the new records restrict.
+func (p *CloudflareProvider) PlanCAA(name string, tags []string, issuer, value string, inherited CAASet) ([]CAAChange, []string, error) {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+
+	zoneID, err := p.lookupZoneID(name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to determine zone for %s: %w", name, err)
+	}
+
+	records, _, err := p.accountFor(zoneID).client.ListDNSRecords(p.ctx, cloudflare.ZoneIdentifier(zoneID), cloudflare.ListDNSRecordsParams{
+		Type: "CAA",
+		Name: name,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list CAA records of %s: %w", name, err)
+	}
+
+	var existing []caaEntry
+	for _, record := range records {
+		data, ok := record.Data.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		tag, _ := data["tag"].(string)
+		value, _ := data["value"].(string)
+		flag, _ := data["flags"].(float64)
+		existing = append(existing, caaEntry{
+			CAARecord: CAARecord{Flag: uint8(flag), Tag: tag, Value: value},
+			recordID:  record.ID,
+			tags:      record.Tags,
+		})
+	}
+
+	changes, warnings := planCAA(name, existing, inherited, tags, issuer, value)
+	for i := range changes {
+		changes[i].zoneID = zoneID
+	}
+	return changes, warnings, nil
+}
+
+// planCAA plans the CAA records at name from the records already there and the inherited set
+func planCAA(name string, existing []caaEntry, inherited CAASet, tags []string, issuer, value string) ([]CAAChange, []string) {
+	var changes []CAAChange
+	var warnings []string
+
+	// Records at name replace the whole set inherited from a parent (RFC 8659), so the
+	// parent's records are copied along to keep authorizing the CAs they do today
+	set := existing
+	if len(existing) == 0 && inherited.Owner != "" && inherited.Owner != name {
+		for _, record := range inherited.Records {
+			changes = append(changes, CAAChange{
+				Name:   name,
+				Flag:   record.Flag,
+				Tag:    strings.ToLower(record.Tag),
+				Value:  record.Value,
+				Action: "create",
+				Reason: "copied from " + inherited.Owner,
+			})
+			set = append(set, caaEntry{CAARecord: record})
+		}
+	}
+
+	var issue []CAARecord
+	hasIssueWild := false
+	for _, entry := range set {
+		switch strings.ToLower(entry.Tag) {
+		case "issue":
+			issue = append(issue, entry.CAARecord)
+		case "issuewild":
+			hasIssueWild = true
+		}
+	}
+
+	for _, tag := range tags {
+		change := CAAChange{Name: name, Tag: tag, Value: value, Action: "create"}
+		for _, entry := range existing {
+			if !strings.EqualFold(entry.Tag, tag) {
+				continue
+			}
+			if entryIssuer, _ := ParseCAAValue(entry.Value); entryIssuer != strings.ToLower(issuer) {
+				continue
+			}
+
+			change.Flag = entry.Flag
+			change.Previous = entry.Value
+			change.recordID = entry.recordID
+			change.tags = entry.tags
+			change.Action = "update"
+			if entry.Value == value {
+				change.Action = "none"
+			}
+			break
+		}
+
+		// A copied record of the same issuer is replaced by the CA's own record
+		if change.Action == "create" {
+			kept := changes[:0]
+			for _, copied := range changes {
+				copiedIssuer, _ := ParseCAAValue(copied.Value)
+				if copied.Reason != "" && strings.EqualFold(copied.Tag, tag) && copiedIssuer == strings.ToLower(issuer) {
+					continue
+				}
+				kept = append(kept, copied)
+			}
+			changes = kept
+		}
+
+		switch {
+		case tag == "issue" && len(issue) == 0:
+			warnings = append(warnings, fmt.Sprintf("No CAA issue records apply to %s, the new record allows only %s to issue for it", name, issuer))
+		case tag == "issuewild" && !hasIssueWild:
+			// Without issuewild records wildcards follow the issue records, which stop
+			// applying to wildcards once an issuewild record exists
+			for _, record := range issue {
+				recordIssuer, _ := ParseCAAValue(record.Value)
+				if recordIssuer == "" || recordIssuer == strings.ToLower(issuer) {
+					continue
+				}
+				changes = append(changes, CAAChange{
+					Name:   name,
+					Flag:   record.Flag,
+					Tag:    "issuewild",
+					Value:  record.Value,
+					Action: "create",
+					Reason: "keeps wildcard issuance allowed by the issue record",
+				})
+			}
+			if len(issue) == 0 {
+				warnings = append(warnings, fmt.Sprintf("No CAA records restrict wildcard issuance for %s, the new record allows only %s to issue wildcards", name, issuer))
+			}
+		}
+
+		changes = append(changes, change)
+	}
+
+	return changes, warnings
+}
+
+// ApplyCAA creates or updates a planned CAA record
+func (p *CloudflareProvider) ApplyCAA(change CAAChange) error {
+	client := p.accountFor(change.zoneID).client
+	data := map[string]interface{}{
+		"flags": change.Flag,
+		"tag":   change.Tag,
+		"value": change.Value,
+	}
+
+	switch change.Action {
+	case "create":
+		_, err := client.CreateDNSRecord(p.ctx, cloudflare.ZoneIdentifier(change.zoneID), cloudflare.CreateDNSRecordParams{
+			Type: "CAA",
+			Name: change.Name,
+			Data: data,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create CAA record %s: %w", change.Name, err)
+		}
+	case "update":
+		_, err := client.UpdateDNSRecord(p.ctx, cloudflare.ZoneIdentifier(change.zoneID), cloudflare.UpdateDNSRecordParams{
+			ID:   change.recordID,
+			Type: "CAA",
+			Name: change.Name,
+			Data: data,
+			Tags: change.tags,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to update CAA record %s: %w", change.Name, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,174 @@
+package dns
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCAASetPermits(t *testing.T) {
+	letsEncrypt := []string{"letsencrypt.org"}
+	account := "https://acme-v02.api.letsencrypt.org/acme/acct/1"
+
+	set := func(records ...CAARecord) CAASet {
+		return CAASet{Owner: "example.com", Records: records}
+	}
+
+	tests := []struct {
+		name     string
+		set      CAASet
+		uri      string
+		wildcard bool
+		want     bool
+	}{
+		{"no records", CAASet{}, "", false, true},
+		{"issuer allowed", set(CAARecord{0, "issue", "letsencrypt.org"}), "", false, true},
+		{"issuer case", set(CAARecord{0, "ISSUE", "LetsEncrypt.org"}), "", false, true},
+		{"other issuer", set(CAARecord{0, "issue", "pki.goog"}), "", false, false},
+		{"one of several", set(CAARecord{0, "issue", "pki.goog"}, CAARecord{0, "issue", "letsencrypt.org"}), "", false, true},
+		{"nobody", set(CAARecord{0, "issue", ";"}), "", false, false},
+		{"only iodef", set(CAARecord{0, "iodef", "mailto:security@example.com"}), "", false, true},
+		{"issuewild ignored for names", set(CAARecord{0, "issue", "letsencrypt.org"}, CAARecord{0, "issuewild", ";"}), "", false, true},
+		{"issuewild wins for wildcards", set(CAARecord{0, "issue", "letsencrypt.org"}, CAARecord{0, "issuewild", ";"}), "", true, false},
+		{"issuewild allows wildcards", set(CAARecord{0, "issue", "pki.goog"}, CAARecord{0, "issuewild", "letsencrypt.org"}), "", true, true},
+		{"issue covers wildcards", set(CAARecord{0, "issue", "letsencrypt.org"}), "", true, true},
+		{"account matches", set(CAARecord{0, "issue", "letsencrypt.org; accounturi=" + account}), account, false, true},
+		{"account differs", set(CAARecord{0, "issue", "letsencrypt.org; accounturi=" + account}), account + "0", false, false},
+		{"no account yet", set(CAARecord{0, "issue", "letsencrypt.org; accounturi=" + account}), "", false, false},
+		{"dns-01 allowed", set(CAARecord{0, "issue", "letsencrypt.org; validationmethods=http-01,dns-01"}), "", false, true},
+		{"dns-01 not allowed", set(CAARecord{0, "issue", "letsencrypt.org; validationmethods=http-01"}), "", false, false},
+		{"unknown property", set(CAARecord{0, "future", "x"}, CAARecord{0, "issue", "letsencrypt.org"}), "", false, true},
+		{"unknown critical property", set(CAARecord{128, "future", "x"}, CAARecord{0, "issue", "letsencrypt.org"}), "", false, false},
+		{"known critical property", set(CAARecord{128, "issue", "letsencrypt.org"}), "", false, true},
+	}
+
+	for _, tt := range tests {
+		err := tt.set.Permits(letsEncrypt, tt.uri, tt.wildcard)
+		if got := err == nil; got != tt.want {
+			t.Errorf("%s: Permits() = %v, want permitted %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestParseCAAValue(t *testing.T) {
+	tests := []struct {
+		value  string
+		issuer string
+		params map[string]string
+	}{
+		{"letsencrypt.org", "letsencrypt.org", map[string]string{}},
+		{" LetsEncrypt.org ", "letsencrypt.org", map[string]string{}},
+		{";", "", map[string]string{}},
+		{"letsencrypt.org; accounturi=https://example.com/acct/1", "letsencrypt.org", map[string]string{"accounturi": "https://example.com/acct/1"}},
+		{"letsencrypt.org;accounturi=a ; ValidationMethods=dns-01", "letsencrypt.org", map[string]string{"accounturi": "a", "validationmethods": "dns-01"}},
+		{"letsencrypt.org; malformed", "letsencrypt.org", map[string]string{}},
+	}
+
+	for _, tt := range tests {
+		issuer, params := ParseCAAValue(tt.value)
+		if issuer != tt.issuer {
+			t.Errorf("ParseCAAValue(%q) issuer = %q, want %q", tt.value, issuer, tt.issuer)
+		}
+		if len(params) != len(tt.params) {
+			t.Errorf("ParseCAAValue(%q) params = %v, want %v", tt.value, params, tt.params)
+			continue
+		}
+		for key, want := range tt.params {
+			if params[key] != want {
+				t.Errorf("ParseCAAValue(%q) params[%q] = %q, want %q", tt.value, key, params[key], want)
+			}
+		}
+	}
+}
+
+func TestPlanCAA(t *testing.T) {
+	const value = "letsencrypt.org; accounturi=https://acme-v02.api.letsencrypt.org/acme/acct/1"
+
+	existing := func(records ...CAARecord) []caaEntry {
+		entries := make([]caaEntry, len(records))
+		for i, record := range records {
+			entries[i] = caaEntry{CAARecord: record, recordID: "record-" + record.Value}
+		}
+		return entries
+	}
+
+	tests := []struct {
+		name      string
+		existing  []caaEntry
+		inherited CAASet
+		tags      []string
+		want      []string // "tag value action"
+		warnings  int
+	}{
+		{
+			name:      "inherited set is copied",
+			inherited: CAASet{Owner: "example.com", Records: []CAARecord{{0, "issue", "pki.goog"}, {0, "iodef", "mailto:security@example.com"}}},
+			tags:      []string{"issue"},
+			want:      []string{"issue pki.goog create", "iodef mailto:security@example.com create", "issue " + value + " create"},
+		},
+		{
+			name:      "inherited record of the issuer is replaced",
+			inherited: CAASet{Owner: "example.com", Records: []CAARecord{{0, "issue", "pki.goog"}, {0, "issue", "letsencrypt.org"}}},
+			tags:      []string{"issue"},
+			want:      []string{"issue pki.goog create", "issue " + value + " create"},
+		},
+		{
+			name:      "own records are not merged with the parent",
+			existing:  existing(CAARecord{0, "issue", "pki.goog"}),
+			inherited: CAASet{Owner: "www.example.com", Records: []CAARecord{{0, "issue", "pki.goog"}}},
+			tags:      []string{"issue"},
+			want:      []string{"issue " + value + " create"},
+		},
+		{
+			name:     "existing record is updated",
+			existing: existing(CAARecord{0, "issue", "letsencrypt.org"}, CAARecord{0, "issue", "pki.goog"}),
+			tags:     []string{"issue"},
+			want:     []string{"issue " + value + " update"},
+		},
+		{
+			name:     "record already in place",
+			existing: existing(CAARecord{0, "issue", value}),
+			tags:     []string{"issue"},
+			want:     []string{"issue " + value + " none"},
+		},
+		{
+			name:     "first issuewild keeps other CAs' wildcards",
+			existing: existing(CAARecord{0, "issue", "pki.goog"}, CAARecord{0, "issue", ";"}),
+			tags:     []string{"issue", "issuewild"},
+			want:     []string{"issue " + value + " create", "issuewild pki.goog create", "issuewild " + value + " create"},
+		},
+		{
+			name:     "existing issuewild set is not extended",
+			existing: existing(CAARecord{0, "issue", "pki.goog"}, CAARecord{0, "issuewild", "sectigo.com"}),
+			tags:     []string{"issue", "issuewild"},
+			want:     []string{"issue " + value + " create", "issuewild " + value + " create"},
+		},
+		{
+			name:     "no CAA anywhere restricts issuance",
+			tags:     []string{"issue", "issuewild"},
+			want:     []string{"issue " + value + " create", "issuewild " + value + " create"},
+			warnings: 2,
+		},
+		{
+			name:     "issue added next to issuewild only",
+			existing: existing(CAARecord{0, "issuewild", "pki.goog"}),
+			tags:     []string{"issue"},
+			want:     []string{"issue " + value + " create"},
+			warnings: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		changes, warnings := planCAA("www.example.com", tt.existing, tt.inherited, tt.tags, "letsencrypt.org", value)
+
+		var got []string
+		for _, change := range changes {
+			got = append(got, change.Tag+" "+change.Value+" "+change.Action)
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("%s: planCAA() = %q, want %q", tt.name, got, tt.want)
+		}
+		if len(warnings) != tt.warnings {
+			t.Errorf("%s: planCAA() warnings = %q, want %d", tt.name, warnings, tt.warnings)
+		}
+	}
+}
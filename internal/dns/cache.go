@@ -0,0 +1,172 @@
+package dns
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bariiss/flarecert/internal/config"
+)
+
+// zoneCache keeps the zone list and resolved domains for the run and, when a path
+// and TTL are set, shares the zone list between runs on disk
+type zoneCache struct {
+	mu       sync.Mutex
+	zones    []ZoneInfo
+	fetched  bool
+	resolved map[string]string // Zone ID by domain
+	path     string
+	ttl      time.Duration
+	accounts string // Fingerprint of the accounts the zones were listed with
+}
+
+// zoneCacheFile is the on-disk format of the zone cache
+type zoneCacheFile struct {
+	FetchedAt time.Time  `json:"fetched_at"`
+	Accounts  string     `json:"accounts"`
+	Zones     []ZoneInfo `json:"zones"`
+}
+
+// AccountsFingerprint identifies the configured Cloudflare accounts, their credentials and the
+// zones pinned to them. Zones cached for other accounts or tokens may be missing or carry stale
+// account names and permissions. Only a hash is kept, the credentials are not written to disk.
+func AccountsFingerprint(credentials []config.CloudflareCredentials, zoneAccounts map[string]string) string {
+	h := sha256.New()
+	for _, creds := range credentials {
+		for _, field := range []string{creds.Name, creds.Mode, creds.APIToken, creds.APIKey, creds.Email, creds.ZoneToken, creds.DNSToken} {
+			h.Write([]byte(field))
+			h.Write([]byte{0})
+		}
+	}
+
+	zones := make([]string, 0, len(zoneAccounts))
+	for zone := range zoneAccounts {
+		zones = append(zones, zone)
+	}
+	sort.Strings(zones)
+	for _, zone := range zones {
+		h.Write([]byte(zone + "=" + zoneAccounts[zone]))
+		h.Write([]byte{0})
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ZoneCachePath returns the location of the on-disk zone cache inside the certificate directory
+func ZoneCachePath(certDir string) string {
+	return filepath.Join(certDir, ".cache", "zones.json")
+}
+
+// LoadCachedZones reads the on-disk zone cache, reporting false when it is missing, older than ttl
+// or was written for other accounts
+func LoadCachedZones(path string, ttl time.Duration, accounts string) ([]ZoneInfo, bool) {
+	if path == "" || ttl <= 0 {
+		return nil, false
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+
+	var file zoneCacheFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, false
+	}
+
+	if time.Since(file.FetchedAt) > ttl || file.Accounts != accounts {
+		return nil, false
+	}
+
+	return file.Zones, true
+}
+
+// SetZoneCache enables the on-disk zone cache at path, entries older than ttl are refetched
+func (p *CloudflareProvider) SetZoneCache(path string, ttl time.Duration) {
+	p.cache.mu.Lock()
+	defer p.cache.mu.Unlock()
+
+	p.cache.path = path
+	p.cache.ttl = ttl
+}
+
+// RefreshZones drops the cached zones and lists them again from Cloudflare
+func (p *CloudflareProvider) RefreshZones() ([]ZoneInfo, error) {
+	p.cache.mu.Lock()
+	p.cache.zones = nil
+	p.cache.fetched = false
+	p.cache.resolved = nil
+	if p.cache.path != "" {
+		os.Remove(p.cache.path)
+	}
+	p.cache.mu.Unlock()
+
+	return p.ListZones()
+}
+
+// get returns the cached zone list from memory or a fresh on-disk cache
+func (c *zoneCache) get() ([]ZoneInfo, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.fetched {
+		return c.zones, true
+	}
+
+	zones, ok := LoadCachedZones(c.path, c.ttl, c.accounts)
+	if ok {
+		c.zones = zones
+		c.fetched = true
+	}
+	return zones, ok
+}
+
+// set stores a freshly fetched zone list
+func (c *zoneCache) set(zones []ZoneInfo) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.zones = zones
+	c.fetched = true
+
+	if c.path == "" || c.ttl <= 0 {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(zoneCacheFile{FetchedAt: time.Now(), Accounts: c.accounts, Zones: zones}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(c.path, data, 0644)
+}
+
+// lookup returns the zone ID a domain was resolved to earlier in the run
+func (c *zoneCache) lookup(domain string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	zoneID, ok := c.resolved[strings.ToLower(domain)]
+	return zoneID, ok
+}
+
+// remember records the zone ID a domain resolved to
+func (c *zoneCache) remember(domain, zoneID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.resolved == nil {
+		c.resolved = make(map[string]string)
+	}
+	c.resolved[strings.ToLower(domain)] = zoneID
+}
@@ -0,0 +1,52 @@
+package dns
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bariiss/flarecert/internal/config"
+)
+
+func TestZoneCacheFingerprint(t *testing.T) {
+	credentials := []config.CloudflareCredentials{{Name: config.DefaultAccount, Mode: config.AuthToken, APIToken: "token-a"}}
+	zoneAccounts := map[string]string{"example.com": config.DefaultAccount}
+
+	path := filepath.Join(t.TempDir(), "zones.json")
+	cache := zoneCache{path: path, ttl: time.Hour, accounts: AccountsFingerprint(credentials, zoneAccounts)}
+	if err := cache.set([]ZoneInfo{{ID: "zone-example", Name: "example.com", Account: config.DefaultAccount}}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		credentials  []config.CloudflareCredentials
+		zoneAccounts map[string]string
+		want         bool
+	}{
+		{"same accounts", credentials, map[string]string{"example.com": config.DefaultAccount}, true},
+		{"token changed", []config.CloudflareCredentials{{Name: config.DefaultAccount, Mode: config.AuthToken, APIToken: "token-b"}}, zoneAccounts, false},
+		{"auth mode changed", []config.CloudflareCredentials{{Name: config.DefaultAccount, Mode: config.AuthGlobalKey, APIKey: "key", Email: "admin@example.com"}}, zoneAccounts, false},
+		{"zone pinned elsewhere", credentials, map[string]string{"example.com": "prod"}, false},
+		{"zone unpinned", credentials, nil, false},
+	}
+
+	for _, tt := range tests {
+		_, ok := LoadCachedZones(path, time.Hour, AccountsFingerprint(tt.credentials, tt.zoneAccounts))
+		if ok != tt.want {
+			t.Errorf("%s: LoadCachedZones() hit = %v, want %v", tt.name, ok, tt.want)
+		}
+	}
+}
+
+func TestAccountsFingerprintZoneOrder(t *testing.T) {
+	credentials := []config.CloudflareCredentials{{Name: config.DefaultAccount, APIToken: "token"}}
+
+	// Map iteration order must not change the fingerprint
+	want := AccountsFingerprint(credentials, map[string]string{"a.example": "prod", "b.example": "staging", "c.example": "prod"})
+	for i := 0; i < 20; i++ {
+		if got := AccountsFingerprint(credentials, map[string]string{"c.example": "prod", "b.example": "staging", "a.example": "prod"}); got != want {
+			t.Fatalf("AccountsFingerprint() = %q, want %q", got, want)
+		}
+	}
+}
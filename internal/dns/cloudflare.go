@@ -2,67 +2,270 @@ package dns
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/bariiss/flarecert/internal/config"
 	"github.com/cloudflare/cloudflare-go"
 	"github.com/go-acme/lego/v4/challenge/dns01"
 )
 
 // CloudflareProvider implements the DNS provider for Cloudflare
 type CloudflareProvider struct {
-	client     *cloudflare.API
-	timeout    time.Duration
-	verbose    bool
-	records    map[string]string // Track created records for cleanup
-	recordsMux sync.RWMutex      // Protect the records map
+	ctx          context.Context    // Cancelled on SIGINT/SIGTERM or Close, aborts API calls and waits
+	cancel       context.CancelFunc // Closes the provider
+	accounts     []*account         // Cloudflare accounts, the default account first
+	transport    *retryTransport    // Retries transient API failures
+	zoneAccounts map[string]string  // Account name by zone name or ID
+	timeout      time.Duration
+	verbose      bool
+	records      map[string]*challengeRecord // Track created records for cleanup
+	recordsMux   sync.RWMutex                // Protect the records, nameservers and zone limit maps
+	aliases      map[string]string           // Explicit _acme-challenge delegations
+	zone         string                      // Zone name or ID used for every domain
+	zoneMap      map[string]string           // Zone ID by domain suffix
+
+	propagation PropagationOptions
+	nameservers map[string][]string // Cloudflare nameservers by zone ID
+	cache       zoneCache           // Zone list and resolved domains
+
+	pendingMux      sync.Mutex               // Protects pending, a WaitGroup must not be added to while waited on
+	pendingDone     *sync.Cond               // Signalled when no record is being created
+	pending         int                      // Records still being created
+	zoneConcurrency int                      // Maximum parallel record creations per zone
+	zoneLimits      map[string]chan struct{} // Per-zone creation slots
+	propagationMux  sync.Mutex               // Serializes the combined propagation wait
+	propagated      bool                     // All created records are visible
+	failure         error                    // First record creation failure, protected by recordsMux
+	failed          chan error               // Receives the first record creation failure
 }
 
-// NewCloudflareProvider creates a new Cloudflare DNS provider
-func NewCloudflareProvider(apiToken, email string, timeout int, verbose bool) (*CloudflareProvider, error) {
-	// Create Cloudflare API client
-	api, err := cloudflare.NewWithAPIToken(apiToken)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create Cloudflare client: %w", err)
+// ChallengeComment tags the challenge records created by flarecert so orphans can be swept
+const ChallengeComment = "flarecert acme-challenge"
+
+// createTimeout bounds the creation of a challenge record, which is not aborted by an
+// interrupt so a record created at Cloudflare is never lost track of
+const createTimeout = 2 * time.Minute
+
+// identicalRecordCode is the Cloudflare error code for creating a record that already exists
+const identicalRecordCode = 81058
+
+// challengeRecord identifies a challenge TXT record
+type challengeRecord struct {
+	ZoneID   string
+	RecordID string
+	FQDN     string
+	Value    string
+	Err      error // Set when the record could not be created
+}
+
+// NewCloudflareProvider creates a new Cloudflare DNS provider whose API requests, including the
+// verification of the credentials, are retried according to policy.
+// Cancelling ctx aborts API calls and propagation waits, but not the cleanup of challenge records.
+func NewCloudflareProvider(ctx context.Context, credentials []config.CloudflareCredentials, timeout int, policy RetryPolicy, verbose bool) (*CloudflareProvider, error) {
+	if len(credentials) == 0 {
+		return nil, fmt.Errorf("no Cloudflare credentials configured")
 	}
 
-	// Verify API token works
-	ctx := context.Background()
-	_, err = api.VerifyAPIToken(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("invalid Cloudflare API token: %w", err)
+	// Create the API clients of every account and verify their credentials
+	transport := newRetryTransport(policy, verbose)
+	var accounts []*account
+	for _, creds := range credentials {
+		acc, err := newAccount(ctx, creds, transport)
+		if err != nil {
+			if len(credentials) > 1 || creds.Name != config.DefaultAccount {
+				return nil, fmt.Errorf("cloudflare account %s: %w", creds.Name, err)
+			}
+			return nil, err
+		}
+		accounts = append(accounts, acc)
 	}
 
 	if verbose {
 		log.Println("Cloudflare API client initialized successfully")
 	}
 
-	return &CloudflareProvider{
-		client:  api,
-		timeout: time.Duration(timeout) * time.Second,
-		verbose: verbose,
-		records: make(map[string]string),
-	}, nil
+	return newProvider(ctx, accounts, transport, timeout, verbose), nil
 }
 
-// Present creates the DNS TXT record for the ACME challenge
-func (p *CloudflareProvider) Present(domain, token, keyAuth string) error {
-	fqdn, value := dns01.GetRecord(domain, keyAuth)
+// NewProviderFromConfig creates a Cloudflare DNS provider with the accounts, retries, zones,
+// challenge delegations and propagation settings of cfg, caching the zone list in certDir
+func NewProviderFromConfig(ctx context.Context, cfg *config.Config, certDir string, verbose bool) (*CloudflareProvider, error) {
+	policy := RetryPolicy{MaxRetries: cfg.CloudflareRetries}
+	p, err := NewCloudflareProvider(ctx, cfg.CloudflareCredentials(), cfg.DNSTimeout, policy, verbose)
+	if err != nil {
+		return nil, err
+	}
 
-	if p.verbose {
-		log.Printf("Creating DNS TXT record: %s = %s", fqdn, value)
+	p.SetChallengeAliases(cfg.ChallengeAliases)
+	p.SetZoneOverrides(cfg.Zone, cfg.ZoneMap)
+	p.SetZoneAccounts(cfg.ZoneAccounts)
+	p.SetZoneCache(ZoneCachePath(certDir), time.Duration(cfg.ZoneCacheTTL)*time.Second)
+	p.cache.accounts = AccountsFingerprint(cfg.CloudflareCredentials(), cfg.ZoneAccounts)
+	p.SetPropagation(PropagationOptions{
+		Interval:      time.Duration(cfg.DNSInterval) * time.Second,
+		FallbackDelay: time.Duration(cfg.DNSFallbackDelay) * time.Second,
+		Resolvers:     cfg.DNSResolvers,
+	})
+	p.SetZoneConcurrency(cfg.DNSZoneConcurrency)
+
+	return p, nil
+}
+
+// newProvider creates a provider for accounts whose credentials are already verified
+func newProvider(ctx context.Context, accounts []*account, transport *retryTransport, timeout int, verbose bool) *CloudflareProvider {
+	p := &CloudflareProvider{
+		accounts:  accounts,
+		transport: transport,
+		timeout:   time.Duration(timeout) * time.Second,
+		verbose:   verbose,
+		records:   make(map[string]*challengeRecord),
+		propagation: PropagationOptions{
+			Interval:      10 * time.Second,
+			FallbackDelay: 30 * time.Second,
+		},
+		nameservers:     make(map[string][]string),
+		zoneConcurrency: 4,
+		zoneLimits:      make(map[string]chan struct{}),
+		failed:          make(chan error, 1),
+	}
+	p.ctx, p.cancel = context.WithCancel(ctx)
+	p.pendingDone = sync.NewCond(&p.pendingMux)
+
+	return p
+}
+
+// SetZoneConcurrency limits how many challenge records are created in parallel per zone
+func (p *CloudflareProvider) SetZoneConcurrency(n int) {
+	if n > 0 {
+		p.zoneConcurrency = n
+	}
+}
+
+// Present starts creating the DNS TXT record for the ACME challenge and returns immediately.
+// lego presents every challenge of an order before solving the first one, so all records
+// are created in parallel and PreCheck waits for them once.
+func (p *CloudflareProvider) Present(domain, token, keyAuth string) error {
+	// No new records once the run is being interrupted or the order was abandoned
+	if err := p.Err(); err != nil {
+		return fmt.Errorf("challenge record for %s not created: %w", domain, err)
 	}
 
-	// Extract the zone name from the domain and get zone ID
+	value := dns01.GetChallengeInfo(domain, keyAuth).Value
+
+	// Delegated challenges are created in the zone the _acme-challenge CNAME points to
+	fqdn, _ := p.challengeFQDN(domain)
+
+	// Resolve the zone up front, selecting it may need user input
 	zoneID, err := p.GetZoneIDForDomain(domain)
 	if err != nil {
 		return fmt.Errorf("failed to determine zone for domain %s: %w", domain, err)
 	}
 
-	ctx := context.Background()
+	record := &challengeRecord{ZoneID: zoneID, FQDN: fqdn, Value: value}
+
+	// Track the record for cleanup (thread-safe)
+	p.recordsMux.Lock()
+	p.records[token] = record
+	p.recordsMux.Unlock()
+
+	p.propagationMux.Lock()
+	p.propagated = false
+	p.propagationMux.Unlock()
+
+	p.pendingMux.Lock()
+	p.pending++
+	p.pendingMux.Unlock()
+
+	go func() {
+		defer p.recordCreated()
+
+		limit := p.zoneLimit(zoneID)
+		limit <- struct{}{}
+		defer func() { <-limit }()
+
+		// Creations still queued when the run is interrupted are dropped
+		recordID, err := "", p.ctx.Err()
+		if err == nil {
+			recordID, err = p.createRecord(zoneID, fqdn, value)
+		}
+
+		p.recordsMux.Lock()
+		record.RecordID, record.Err = recordID, err
+		p.recordsMux.Unlock()
+
+		if err != nil && !errors.Is(err, context.Canceled) {
+			p.fail(fmt.Errorf("challenge record %s was not created: %w", dns01.UnFqdn(fqdn), err))
+		}
+	}()
+
+	return nil
+}
+
+// fail records the first record creation failure. lego keeps polling PreCheck until the
+// propagation timeout on errors, so the failure is also delivered to CreateFailed.
+func (p *CloudflareProvider) fail(err error) {
+	p.recordsMux.Lock()
+	defer p.recordsMux.Unlock()
+
+	if p.failure != nil {
+		return
+	}
+	p.failure = err
+	p.failed <- err
+}
+
+// CreateFailed receives the first challenge record that could not be created, the order
+// cannot succeed anymore and should be abandoned
+func (p *CloudflareProvider) CreateFailed() <-chan error {
+	return p.failed
+}
+
+// Close abandons the order: further Present calls are rejected, queued record creations are
+// dropped and PreCheck stops waiting. Records already created are still removed by CleanUpAll.
+func (p *CloudflareProvider) Close() {
+	p.cancel()
+}
+
+// Err returns a non-nil error once the provider was closed or the run interrupted
+func (p *CloudflareProvider) Err() error {
+	return p.ctx.Err()
+}
+
+// recordCreated marks a background record creation as finished
+func (p *CloudflareProvider) recordCreated() {
+	p.pendingMux.Lock()
+	defer p.pendingMux.Unlock()
+
+	p.pending--
+	if p.pending == 0 {
+		p.pendingDone.Broadcast()
+	}
+}
+
+// waitPending waits until no record is being created. Present may start new creations meanwhile.
+func (p *CloudflareProvider) waitPending() {
+	p.pendingMux.Lock()
+	defer p.pendingMux.Unlock()
+
+	for p.pending > 0 {
+		p.pendingDone.Wait()
+	}
+}
+
+// createRecord creates a challenge TXT record and returns its ID
+func (p *CloudflareProvider) createRecord(zoneID, fqdn, value string) (string, error) {
+	if p.verbose {
+		log.Printf("Creating DNS TXT record: %s = %s", fqdn, value)
+	}
+
+	// A create in flight when the run is interrupted must finish, or CleanUp cannot find the record
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(p.ctx), createTimeout)
+	defer cancel()
 
 	// Create the DNS record
 	recordName := strings.TrimSuffix(fqdn, ".")
@@ -71,37 +274,74 @@ func (p *CloudflareProvider) Present(domain, token, keyAuth string) error {
 		Name:    recordName,
 		Content: value,
 		TTL:     60, // Short TTL for quick propagation
+		Comment: ChallengeComment,
 	}
 
-	response, err := p.client.CreateDNSRecord(ctx, cloudflare.ZoneIdentifier(zoneID), createParams)
+	response, err := p.accountFor(zoneID).client.CreateDNSRecord(ctx, cloudflare.ZoneIdentifier(zoneID), createParams)
 	if err != nil {
-		return fmt.Errorf("failed to create DNS record: %w", err)
+		// A retried request may already have created the record
+		var requestErr *cloudflare.RequestError
+		if errors.As(err, &requestErr) && requestErr.InternalErrorCodeIs(identicalRecordCode) {
+			return p.findRecord(ctx, zoneID, recordName, value)
+		}
+		return "", fmt.Errorf("failed to create DNS record %s: %w", recordName, err)
 	}
 
-	// Store record ID for cleanup (thread-safe)
-	p.recordsMux.Lock()
-	p.records[token] = response.ID
-	p.recordsMux.Unlock()
-
 	if p.verbose {
 		log.Printf("DNS record created successfully: %s", response.ID)
-		log.Printf("Waiting for DNS propagation (up to %v)...", p.timeout)
 	}
 
-	// Simple sleep for DNS propagation - more reliable than complex checking
-	time.Sleep(30 * time.Second)
+	return response.ID, nil
+}
 
-	return nil
+// findRecord returns the ID of an existing challenge TXT record
+func (p *CloudflareProvider) findRecord(ctx context.Context, zoneID, recordName, value string) (string, error) {
+	records, _, err := p.accountFor(zoneID).client.ListDNSRecords(ctx, cloudflare.ZoneIdentifier(zoneID), cloudflare.ListDNSRecordsParams{
+		Type:    "TXT",
+		Name:    recordName,
+		Content: value,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to look up existing DNS record %s: %w", recordName, err)
+	}
+	if len(records) == 0 {
+		return "", fmt.Errorf("DNS record %s reported as existing but not found", recordName)
+	}
+
+	if p.verbose {
+		log.Printf("DNS record already exists: %s", records[0].ID)
+	}
+
+	return records[0].ID, nil
+}
+
+// zoneLimit returns the creation slots of a zone
+func (p *CloudflareProvider) zoneLimit(zoneID string) chan struct{} {
+	p.recordsMux.Lock()
+	defer p.recordsMux.Unlock()
+
+	limit, ok := p.zoneLimits[zoneID]
+	if !ok {
+		limit = make(chan struct{}, p.zoneConcurrency)
+		p.zoneLimits[zoneID] = limit
+	}
+	return limit
 }
 
 // CleanUp removes the DNS TXT record after the challenge is complete
 func (p *CloudflareProvider) CleanUp(domain, token, keyAuth string) error {
-	// Get record ID (thread-safe)
-	p.recordsMux.RLock()
-	recordID, exists := p.records[token]
-	p.recordsMux.RUnlock()
+	// Wait for records still being created so none is left behind
+	p.waitPending()
+
+	// Get and forget the record (thread-safe)
+	p.recordsMux.Lock()
+	record, exists := p.records[token]
+	delete(p.records, token)
+	p.recordsMux.Unlock()
 
-	if !exists {
+	// Failed creations are reported through CreateFailed, and like creations dropped because
+	// the run was interrupted they left nothing behind
+	if !exists || record.RecordID == "" {
 		if p.verbose {
 			log.Printf("No record ID found for token %s, skipping cleanup", token)
 		}
@@ -109,28 +349,16 @@ func (p *CloudflareProvider) CleanUp(domain, token, keyAuth string) error {
 	}
 
 	if p.verbose {
-		log.Printf("Cleaning up DNS record: %s", recordID)
+		log.Printf("Cleaning up DNS record: %s", record.RecordID)
 	}
 
-	// Extract the zone name from the domain and get zone ID
-	zoneID, err := p.GetZoneIDForDomain(domain)
-	if err != nil {
-		return fmt.Errorf("failed to determine zone for domain %s: %w", domain, err)
-	}
-
-	ctx := context.Background()
-
-	// Delete the DNS record
-	err = p.client.DeleteDNSRecord(ctx, cloudflare.ZoneIdentifier(zoneID), recordID)
-	if err != nil {
-		return fmt.Errorf("failed to delete DNS record: %w", err)
+	// Delete the DNS record from the zone it was created in, a record that is already gone
+	// (e.g. a retried delete that succeeded the first time) is fine
+	if err := p.deleteRecord(record.ZoneID, record.RecordID); err != nil {
+		return fmt.Errorf("failed to delete DNS record %s in zone %s, run 'flarecert dns cleanup' to remove it: %w",
+			dns01.UnFqdn(record.FQDN), record.ZoneID, err)
 	}
 
-	// Remove from tracking (thread-safe)
-	p.recordsMux.Lock()
-	delete(p.records, token)
-	p.recordsMux.Unlock()
-
 	if p.verbose {
 		log.Printf("DNS record cleaned up successfully")
 	}
@@ -138,7 +366,30 @@ func (p *CloudflareProvider) CleanUp(domain, token, keyAuth string) error {
 	return nil
 }
 
-// Timeout returns the timeout duration for DNS propagation
+// CleanUpAll removes every challenge record still tracked, e.g. after the order was interrupted
+func (p *CloudflareProvider) CleanUpAll() error {
+	// Records still being created are only tracked with their ID once done
+	p.waitPending()
+
+	p.recordsMux.RLock()
+	tokens := make([]string, 0, len(p.records))
+	for token := range p.records {
+		tokens = append(tokens, token)
+	}
+	p.recordsMux.RUnlock()
+
+	var errs []error
+	for _, token := range tokens {
+		if err := p.CleanUp("", token, ""); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Timeout returns the timeout duration for DNS propagation. PreCheck polls on its own,
+// so lego only needs a short interval between the challenges of an order.
 func (p *CloudflareProvider) Timeout() (timeout, interval time.Duration) {
-	return p.timeout, 10 * time.Second
+	return p.timeout, time.Second
 }
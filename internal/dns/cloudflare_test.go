@@ -0,0 +1,117 @@
+package dns
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bariiss/flarecert/internal/config"
+	"github.com/cloudflare/cloudflare-go"
+)
+
+// newTestProvider creates a provider whose default account talks to the Cloudflare API
+// served by handler. Challenge records are not looked up for delegations.
+func newTestProvider(t *testing.T, handler http.Handler) *CloudflareProvider {
+	t.Helper()
+	t.Setenv("LEGO_DISABLE_CNAME_SUPPORT", "true")
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	transport := newRetryTransport(DefaultRetryPolicy, false)
+	api, err := cloudflare.NewWithAPIToken("token",
+		cloudflare.BaseURL(server.URL),
+		cloudflare.HTTPClient(&http.Client{Transport: transport}),
+		cloudflare.UsingRetryPolicy(0, 0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	acc := &account{name: config.DefaultAccount, mode: config.AuthToken, client: api, zoneClient: api}
+	return newProvider(context.Background(), []*account{acc}, transport, 300, false)
+}
+
+func TestPresentCreateFailure(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /zones", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"success":true,"errors":[],"messages":[],"result":[{"id":"zone-example","name":"example.com","status":"active"}],"result_info":{"page":1,"per_page":50,"count":1,"total_count":1,"total_pages":1}}`))
+	})
+	mux.HandleFunc("POST /zones/zone-example/dns_records", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"success":false,"errors":[{"code":10000,"message":"Authentication error"}],"messages":[],"result":null}`))
+	})
+
+	p := newTestProvider(t, mux)
+
+	for _, domain := range []string{"example.com", "www.example.com"} {
+		if err := p.Present(domain, domain, "key-auth"); err != nil {
+			t.Fatalf("Present(%q) = %v, want nil", domain, err)
+		}
+	}
+
+	select {
+	case err := <-p.CreateFailed():
+		if err == nil {
+			t.Fatal("CreateFailed() delivered nil, want the creation error")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("CreateFailed() did not deliver the creation error")
+	}
+
+	// Every challenge of the order fails at once instead of polling until the timeout
+	start := time.Now()
+	for _, domain := range []string{"example.com", "www.example.com"} {
+		ok, err := p.PreCheck(domain, "", "value", nil)
+		if ok || err == nil {
+			t.Errorf("PreCheck(%q) = %v, %v, want false and the creation error", domain, ok, err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("PreCheck took %v, want no wait", elapsed)
+	}
+
+	// Nothing was created, so there is nothing to remove
+	if err := p.CleanUpAll(); err != nil {
+		t.Errorf("CleanUpAll() = %v, want nil", err)
+	}
+}
+
+func TestClosedProvider(t *testing.T) {
+	created := make(chan struct{}, 1)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /zones", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"success":true,"errors":[],"messages":[],"result":[{"id":"zone-example","name":"example.com","status":"active"}],"result_info":{"page":1,"per_page":50,"count":1,"total_count":1,"total_pages":1}}`))
+	})
+	mux.HandleFunc("POST /zones/zone-example/dns_records", func(w http.ResponseWriter, r *http.Request) {
+		created <- struct{}{}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"success":true,"errors":[],"messages":[],"result":{"id":"record-1"}}`))
+	})
+
+	p := newTestProvider(t, mux)
+	p.Close()
+
+	if err := p.Present("example.com", "token", "key-auth"); err == nil {
+		t.Error("Present() after Close() = nil, want an error")
+	}
+
+	// lego only stops polling on success, its requests to the CA fail afterwards
+	if ok, err := p.PreCheck("example.com", "", "value", nil); !ok || err != nil {
+		t.Errorf("PreCheck() after Close() = %v, %v, want true, nil", ok, err)
+	}
+
+	if err := p.CleanUpAll(); err != nil {
+		t.Errorf("CleanUpAll() = %v, want nil", err)
+	}
+
+	select {
+	case <-created:
+		t.Error("a challenge record was created after Close()")
+	default:
+	}
+}
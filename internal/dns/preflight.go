@@ -0,0 +1,109 @@
+package dns
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bariiss/flarecert/internal/config"
+	"github.com/cloudflare/cloudflare-go"
+)
+
+// TokenInfo describes a Cloudflare API token in use
+type TokenInfo struct {
+	Name      string // API token, zone token or DNS token
+	ID        string
+	Status    string // active, disabled or expired
+	ExpiresOn time.Time
+}
+
+// ZoneAccess describes what the API token may do in a zone
+type ZoneAccess struct {
+	Zone      ZoneInfo
+	Read      bool  // DNS records can be listed
+	Edit      bool  // DNS records can be created and deleted
+	EditKnown bool  // Edit was determined from the zone permissions or a write test
+	Err       error // Why reading or editing failed
+}
+
+// CheckZoneAccess checks that the token can list DNS records in a zone and whether it may edit
+// them, from the permissions Cloudflare reports for the zone. With writeTest edit access is
+// verified by creating and deleting a probe record instead.
+func (p *CloudflareProvider) CheckZoneAccess(zone ZoneInfo, writeTest bool) ZoneAccess {
+	access := ZoneAccess{Zone: zone}
+
+	acc := p.accountFor(zone.ID)
+
+	_, _, err := acc.client.ListDNSRecords(p.ctx, cloudflare.ZoneIdentifier(zone.ID), cloudflare.ListDNSRecordsParams{
+		Type:       "TXT",
+		ResultInfo: cloudflare.ResultInfo{PerPage: 1, Page: 1},
+	})
+	if err != nil {
+		access.Err = fmt.Errorf("cannot list DNS records: %w", err)
+		return access
+	}
+	access.Read = true
+
+	// With split tokens the zone permissions are those of the zone token, not the DNS token
+	if !writeTest {
+		access.EditKnown = len(zone.Permissions) > 0 && acc.mode != config.AuthSplit
+		for _, permission := range zone.Permissions {
+			if permission == "#dns_records:edit" {
+				access.Edit = true
+			}
+		}
+		return access
+	}
+
+	access.EditKnown = true
+	if err := p.writeProbe(zone); err != nil {
+		access.Err = err
+		return access
+	}
+	access.Edit = true
+
+	return access
+}
+
+// writeProbe creates and deletes a TXT record to prove edit access to a zone
+func (p *CloudflareProvider) writeProbe(zone ZoneInfo) error {
+	name := "_flarecert-doctor." + strings.TrimSuffix(zone.Name, ".")
+	response, err := p.accountFor(zone.ID).client.CreateDNSRecord(p.ctx, cloudflare.ZoneIdentifier(zone.ID), cloudflare.CreateDNSRecordParams{
+		Type:    "TXT",
+		Name:    name,
+		Content: "flarecert write test",
+		TTL:     60,
+		Comment: ChallengeComment,
+	})
+	if err != nil {
+		return fmt.Errorf("cannot create DNS records: %w", err)
+	}
+
+	if err := p.deleteRecord(zone.ID, response.ID); err != nil {
+		return fmt.Errorf("created probe record %s but cannot delete it: %w", name, err)
+	}
+
+	return nil
+}
+
+// ZoneForDomain returns the zone holding the challenge records of a domain without prompting,
+// following --zone, the zone map and challenge delegations
+func (p *CloudflareProvider) ZoneForDomain(domain string) (ZoneInfo, error) {
+	zoneID, err := p.challengeZoneID(strings.TrimPrefix(domain, "*."))
+	if err != nil {
+		return ZoneInfo{}, err
+	}
+
+	zones, err := p.ListZones()
+	if err != nil {
+		return ZoneInfo{}, err
+	}
+	for _, zone := range zones {
+		if zone.ID == zoneID {
+			return zone, nil
+		}
+	}
+
+	// Mapped zone IDs may not be listable with a token restricted to DNS:Edit
+	return ZoneInfo{ID: zoneID, Name: zoneID}, nil
+}
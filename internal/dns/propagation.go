@@ -0,0 +1,256 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/go-acme/lego/v4/challenge/dns01"
+	"github.com/miekg/dns"
+)
+
+// PropagationOptions controls how challenge record propagation is checked
+type PropagationOptions struct {
+	// Interval is the time between propagation checks
+	Interval time.Duration
+	// FallbackDelay is waited instead when no nameserver can be queried
+	FallbackDelay time.Duration
+	// Resolvers are additional nameservers that must see the record
+	Resolvers []string
+}
+
+// SetPropagation sets the propagation check options
+func (p *CloudflareProvider) SetPropagation(opts PropagationOptions) {
+	p.propagation = opts
+}
+
+// PreCheck reports whether the challenge TXT records are visible on their zones' Cloudflare
+// nameservers and the configured resolvers. The first call waits for every record of the
+// order at once, later calls return immediately until new records are presented.
+// Once a record could not be created every call fails without waiting. Once the provider is
+// closed every call reports the check as done, lego only stops polling on success and its
+// requests to the CA are rejected after the order was abandoned.
+func (p *CloudflareProvider) PreCheck(domain, _, value string, _ dns01.PreCheckFunc) (bool, error) {
+	if p.Err() != nil {
+		return true, nil
+	}
+
+	// Records are created in the background by Present
+	p.waitPending()
+
+	p.recordsMux.RLock()
+	failure := p.failure
+	p.recordsMux.RUnlock()
+	if failure != nil {
+		return false, failure
+	}
+
+	fqdn, _ := p.challengeFQDN(domain)
+	for _, record := range p.challengeRecords() {
+		if record.FQDN == fqdn && record.Value == value && record.Err != nil {
+			return false, record.Err
+		}
+	}
+
+	return p.waitForPropagation()
+}
+
+// waitForPropagation polls until every created challenge record is visible or the timeout expires.
+// It returns an error when the run is interrupted.
+func (p *CloudflareProvider) waitForPropagation() (bool, error) {
+	p.propagationMux.Lock()
+	defer p.propagationMux.Unlock()
+
+	if p.propagated {
+		return true, nil
+	}
+
+	deadline := time.Now().Add(p.timeout)
+	for {
+		visible, unreachable := 0, 0
+		records := p.challengeRecords()
+		for _, record := range records {
+			if record.Err != nil {
+				visible++
+				continue
+			}
+
+			ok, queried := p.checkRecord(record.FQDN, record.Value)
+			if ok {
+				visible++
+			}
+			if queried == 0 {
+				unreachable++
+			}
+		}
+
+		// Nothing could be queried, e.g. outbound DNS is blocked, so wait a fixed time instead
+		if unreachable > 0 && unreachable == len(records) {
+			if p.verbose {
+				log.Printf("No nameserver reachable, waiting %v instead", p.propagation.FallbackDelay)
+			}
+			if err := p.sleep(p.propagation.FallbackDelay); err != nil {
+				return false, err
+			}
+			p.propagated = true
+			return true, nil
+		}
+
+		if visible == len(records) {
+			if p.verbose {
+				log.Printf("All %d challenge record(s) are visible", len(records))
+			}
+			p.propagated = true
+			return true, nil
+		}
+
+		if time.Now().Add(p.propagation.Interval).After(deadline) {
+			return false, nil
+		}
+
+		if p.verbose {
+			log.Printf("Waiting for DNS propagation: %d/%d record(s) visible", visible, len(records))
+		}
+		if err := p.sleep(p.propagation.Interval); err != nil {
+			return false, err
+		}
+	}
+}
+
+// sleep waits for d unless the run is interrupted first
+func (p *CloudflareProvider) sleep(d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-p.ctx.Done():
+		return fmt.Errorf("stopped waiting for DNS propagation: %w", p.ctx.Err())
+	}
+}
+
+// checkRecord reports whether the TXT value at fqdn is visible on every reachable nameserver,
+// and how many nameservers could be queried
+func (p *CloudflareProvider) checkRecord(fqdn, value string) (bool, int) {
+	var zoneID string
+	for _, record := range p.challengeRecords() {
+		if record.FQDN == fqdn {
+			zoneID = record.ZoneID
+			break
+		}
+	}
+
+	var nameservers []string
+	nameservers = append(nameservers, p.zoneNameservers(zoneID)...)
+	nameservers = append(nameservers, p.propagation.Resolvers...)
+
+	queried := 0
+	for _, ns := range nameservers {
+		values, err := lookupTXT(p.ctx, fqdn, ns)
+		if err != nil {
+			if p.verbose {
+				log.Printf("Propagation check for %s on %s failed: %v", fqdn, ns, err)
+			}
+			continue
+		}
+		queried++
+
+		if !containsValue(values, value) {
+			if p.verbose {
+				log.Printf("TXT record %s not yet visible on %s", fqdn, ns)
+			}
+			return false, queried
+		}
+	}
+
+	return queried > 0, queried
+}
+
+// challengeRecords returns a snapshot of the presented challenge records
+func (p *CloudflareProvider) challengeRecords() []challengeRecord {
+	p.recordsMux.RLock()
+	defer p.recordsMux.RUnlock()
+
+	records := make([]challengeRecord, 0, len(p.records))
+	for _, record := range p.records {
+		records = append(records, *record)
+	}
+	return records
+}
+
+// zoneNameservers returns the Cloudflare nameservers of a zone
+func (p *CloudflareProvider) zoneNameservers(zoneID string) []string {
+	if zoneID == "" {
+		return nil
+	}
+
+	p.recordsMux.RLock()
+	nameservers, ok := p.nameservers[zoneID]
+	p.recordsMux.RUnlock()
+	if ok {
+		return nameservers
+	}
+
+	// Look the zone up without holding the lock, Present and CleanUp must not wait on the API
+	zone, err := p.accountFor(zoneID).zoneClient.ZoneDetails(p.ctx, zoneID)
+	if err != nil {
+		if p.verbose {
+			log.Printf("Failed to look up nameservers of zone %s: %v", zoneID, err)
+		}
+		return nil
+	}
+
+	p.recordsMux.Lock()
+	defer p.recordsMux.Unlock()
+
+	// Another caller may have stored them in the meantime
+	if nameservers, ok := p.nameservers[zoneID]; ok {
+		return nameservers
+	}
+	p.nameservers[zoneID] = zone.NameServers
+	return zone.NameServers
+}
+
+// lookupTXT queries a single nameserver for the TXT records at fqdn
+func lookupTXT(ctx context.Context, fqdn, nameserver string) ([]string, error) {
+	if _, _, err := net.SplitHostPort(nameserver); err != nil {
+		nameserver = net.JoinHostPort(nameserver, "53")
+	}
+
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns01.ToFqdn(fqdn), dns.TypeTXT)
+	msg.RecursionDesired = true
+
+	client := &dns.Client{Timeout: 5 * time.Second}
+	resp, _, err := client.ExchangeContext(ctx, msg, nameserver)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Rcode != dns.RcodeSuccess && resp.Rcode != dns.RcodeNameError {
+		return nil, fmt.Errorf("unexpected response code %s", dns.RcodeToString[resp.Rcode])
+	}
+
+	var values []string
+	for _, rr := range resp.Answer {
+		if txt, ok := rr.(*dns.TXT); ok {
+			values = append(values, strings.Join(txt.Txt, ""))
+		}
+	}
+
+	return values, nil
+}
+
+// containsValue reports whether value is one of the TXT record values
+func containsValue(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,222 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RetryPolicy controls how transient Cloudflare API failures are retried
+type RetryPolicy struct {
+	MaxRetries int           // Retries after the first attempt
+	MinDelay   time.Duration // Backoff before the first retry, doubled on each retry
+	MaxDelay   time.Duration // Upper bound of the backoff
+}
+
+// DefaultRetryPolicy supplies the delays a RetryPolicy leaves unset
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries: 5,
+	MinDelay:   time.Second,
+	MaxDelay:   30 * time.Second,
+}
+
+// maxRetryAfter caps the wait requested by a Retry-After header
+const maxRetryAfter = 5 * time.Minute
+
+// idPattern matches Cloudflare zone and record IDs in request paths
+var idPattern = regexp.MustCompile(`/[0-9a-f]{32}`)
+
+// retryTransport retries Cloudflare API requests on network errors, HTTP 429 and 5xx
+// responses with exponential backoff and jitter, honouring Retry-After.
+type retryTransport struct {
+	base    http.RoundTripper
+	policy  RetryPolicy
+	verbose bool
+
+	mu      sync.Mutex
+	retries map[string]int // Retries by API operation
+	waited  time.Duration  // Total time spent backing off
+}
+
+// newRetryTransport wraps the default HTTP transport
+func newRetryTransport(policy RetryPolicy, verbose bool) *retryTransport {
+	return &retryTransport{
+		base:    http.DefaultTransport,
+		policy:  policy.withDefaults(),
+		verbose: verbose,
+		retries: make(map[string]int),
+	}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	operation := req.Method + " " + idPattern.ReplaceAllString(req.URL.Path, "/:id")
+
+	// Requests whose body cannot be replayed are sent once
+	replayable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+
+	attemptReq := req
+	for attempt := 0; ; attempt++ {
+		resp, err := t.base.RoundTrip(attemptReq)
+
+		delay, reason, retry := t.backoff(ctx, resp, err, attempt)
+		if !retry || !replayable || attempt >= t.policy.MaxRetries {
+			return resp, err
+		}
+
+		// Release the failed response before trying again
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		t.record(operation, delay)
+		if t.verbose {
+			log.Printf("⏳ Cloudflare API %s (%s), retrying in %s (retry %d/%d)",
+				operation, reason, delay.Round(time.Millisecond), attempt+1, t.policy.MaxRetries)
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, fmt.Errorf("cloudflare API %s aborted while retrying: %w", operation, ctx.Err())
+		}
+
+		attemptReq = req.Clone(ctx)
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("failed to replay request body: %w", err)
+			}
+			attemptReq.Body = body
+		}
+	}
+}
+
+// backoff decides whether a response is transient and how long to wait before retrying
+func (t *retryTransport) backoff(ctx context.Context, resp *http.Response, err error, attempt int) (time.Duration, string, bool) {
+	var reason string
+	switch {
+	case err != nil:
+		// Cancellation by the caller is final
+		if ctx.Err() != nil {
+			return 0, "", false
+		}
+		reason = err.Error()
+	case resp.StatusCode == http.StatusTooManyRequests:
+		reason = "rate limited"
+	case resp.StatusCode >= http.StatusInternalServerError:
+		reason = fmt.Sprintf("HTTP %d", resp.StatusCode)
+	default:
+		return 0, "", false
+	}
+
+	// The server knows best when it accepts requests again
+	if resp != nil {
+		if delay, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			return delay, reason, true
+		}
+	}
+
+	// Exponential backoff with equal jitter
+	delay := t.policy.MinDelay << attempt
+	if delay <= 0 || delay > t.policy.MaxDelay {
+		delay = t.policy.MaxDelay
+	}
+	if half := delay / 2; half > 0 {
+		delay = half + time.Duration(rand.Int63n(int64(half)+1))
+	}
+
+	return delay, reason, true
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	var delay time.Duration
+	if seconds, err := strconv.Atoi(strings.TrimSpace(value)); err == nil {
+		delay = time.Duration(seconds) * time.Second
+	} else if date, err := http.ParseTime(value); err == nil {
+		delay = time.Until(date)
+	} else {
+		return 0, false
+	}
+
+	if delay < 0 {
+		delay = 0
+	}
+	if delay > maxRetryAfter {
+		delay = maxRetryAfter
+	}
+	return delay, true
+}
+
+// record counts a retry of an operation
+func (t *retryTransport) record(operation string, delay time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.retries[operation]++
+	t.waited += delay
+}
+
+// summary describes the retries so far, empty when there were none
+func (t *retryTransport) summary() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.retries) == 0 {
+		return ""
+	}
+
+	total := 0
+	operations := make([]string, 0, len(t.retries))
+	for operation, count := range t.retries {
+		total += count
+		operations = append(operations, fmt.Sprintf("%s: %d", operation, count))
+	}
+	sort.Strings(operations)
+
+	return fmt.Sprintf("%d retries, %s spent backing off (%s)",
+		total, t.waited.Round(time.Second), strings.Join(operations, ", "))
+}
+
+// withDefaults returns the policy with unset delays taken from DefaultRetryPolicy
+func (policy RetryPolicy) withDefaults() RetryPolicy {
+	if policy.MaxRetries < 0 {
+		policy.MaxRetries = 0
+	}
+	if policy.MinDelay <= 0 {
+		policy.MinDelay = DefaultRetryPolicy.MinDelay
+	}
+	if policy.MaxDelay <= 0 {
+		policy.MaxDelay = DefaultRetryPolicy.MaxDelay
+	}
+	if policy.MaxDelay < policy.MinDelay {
+		policy.MaxDelay = policy.MinDelay
+	}
+	return policy
+}
+
+// LogRetrySummary logs the Cloudflare API retries in verbose mode
+func (p *CloudflareProvider) LogRetrySummary() {
+	if !p.verbose {
+		return
+	}
+	if summary := p.transport.summary(); summary != "" {
+		log.Printf("🔁 Cloudflare API retries: %s", summary)
+	}
+}
@@ -0,0 +1,121 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"empty", "", 0, false},
+		{"seconds", "5", 5 * time.Second, true},
+		{"seconds with spaces", " 10 ", 10 * time.Second, true},
+		{"negative seconds", "-3", 0, true},
+		{"capped", "600", maxRetryAfter, true},
+		{"past date", "Mon, 02 Jan 2006 15:04:05 GMT", 0, true},
+		{"far future date", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat), maxRetryAfter, true},
+		{"garbage", "soon", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseRetryAfter(tt.value)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("%s: parseRetryAfter(%q) = %v, %v, want %v, %v", tt.name, tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseRetryAfterDate(t *testing.T) {
+	value := time.Now().Add(2 * time.Minute).UTC().Format(http.TimeFormat)
+
+	got, ok := parseRetryAfter(value)
+	if !ok || got < time.Minute || got > 2*time.Minute {
+		t.Errorf("parseRetryAfter(%q) = %v, %v, want about 2m, true", value, got, ok)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	transport := &retryTransport{policy: RetryPolicy{MaxRetries: 5, MinDelay: time.Second, MaxDelay: 30 * time.Second}}
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	response := func(status int, retryAfter string) *http.Response {
+		resp := &http.Response{StatusCode: status, Header: make(http.Header)}
+		if retryAfter != "" {
+			resp.Header.Set("Retry-After", retryAfter)
+		}
+		return resp
+	}
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		resp     *http.Response
+		err      error
+		attempt  int
+		retry    bool
+		min, max time.Duration
+	}{
+		{"network error", context.Background(), nil, errors.New("connection reset"), 0, true, 500 * time.Millisecond, time.Second},
+		{"cancelled", cancelled, nil, context.Canceled, 0, false, 0, 0},
+		{"rate limited", context.Background(), response(http.StatusTooManyRequests, ""), nil, 2, true, 2 * time.Second, 4 * time.Second},
+		{"retry after", context.Background(), response(http.StatusTooManyRequests, "7"), nil, 0, true, 7 * time.Second, 7 * time.Second},
+		{"server error", context.Background(), response(http.StatusServiceUnavailable, ""), nil, 1, true, time.Second, 2 * time.Second},
+		{"capped", context.Background(), response(http.StatusBadGateway, ""), nil, 10, true, 15 * time.Second, 30 * time.Second},
+		{"shift overflow", context.Background(), response(http.StatusBadGateway, ""), nil, 70, true, 15 * time.Second, 30 * time.Second},
+		{"client error", context.Background(), response(http.StatusNotFound, "5"), nil, 0, false, 0, 0},
+		{"success", context.Background(), response(http.StatusOK, ""), nil, 0, false, 0, 0},
+	}
+
+	for _, tt := range tests {
+		delay, _, retry := transport.backoff(tt.ctx, tt.resp, tt.err, tt.attempt)
+		if retry != tt.retry {
+			t.Errorf("%s: retry = %v, want %v", tt.name, retry, tt.retry)
+			continue
+		}
+		if delay < tt.min || delay > tt.max {
+			t.Errorf("%s: delay = %v, want between %v and %v", tt.name, delay, tt.min, tt.max)
+		}
+	}
+}
+
+func TestRetryTransportPolicy(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy RetryPolicy
+		want   int
+	}{
+		{"no retries", RetryPolicy{MaxRetries: 0}, 1},
+		{"two retries", RetryPolicy{MaxRetries: 2, MinDelay: time.Millisecond, MaxDelay: time.Millisecond}, 3},
+		{"negative retries", RetryPolicy{MaxRetries: -1}, 1},
+	}
+
+	for _, tt := range tests {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+
+		// The policy applies from the first request on, e.g. the token verification
+		client := &http.Client{Transport: newRetryTransport(tt.policy, false)}
+		resp, err := client.Get(server.URL + "/user/tokens/verify")
+		if err == nil {
+			resp.Body.Close()
+		}
+		server.Close()
+
+		if requests != tt.want {
+			t.Errorf("%s: %d requests, want %d", tt.name, requests, tt.want)
+		}
+	}
+}
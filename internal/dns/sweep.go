@@ -0,0 +1,109 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cloudflare/cloudflare-go"
+)
+
+// cleanupTimeout bounds the deletion of a challenge record after the run was interrupted
+const cleanupTimeout = 30 * time.Second
+
+// StaleRecord is a challenge TXT record left behind by an interrupted order or a failed cleanup
+type StaleRecord struct {
+	ZoneID    string
+	ZoneName  string
+	RecordID  string
+	Name      string
+	Content   string
+	CreatedOn time.Time
+	Tagged    bool // Carries the flarecert challenge comment
+}
+
+// FindStaleRecords lists challenge records created more than olderThan ago in every active zone.
+// Only records tagged with ChallengeComment are returned unless includeUntagged is set, which
+// adds any _acme-challenge TXT record, e.g. ones created before records were tagged.
+func (p *CloudflareProvider) FindStaleRecords(olderThan time.Duration, includeUntagged bool) ([]StaleRecord, error) {
+	zones, err := p.ListZones()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := p.ctx
+	cutoff := time.Now().Add(-olderThan)
+
+	var stale []StaleRecord
+	for _, zone := range zones {
+		if zone.Status != "active" {
+			continue
+		}
+
+		params := cloudflare.ListDNSRecordsParams{Type: "TXT"}
+		if !includeUntagged {
+			params.Comment = ChallengeComment
+		}
+
+		records, _, err := p.accountFor(zone.ID).client.ListDNSRecords(ctx, cloudflare.ZoneIdentifier(zone.ID), params)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list DNS records of zone %s: %w", zone.Name, err)
+		}
+
+		for _, record := range records {
+			tagged := record.Comment == ChallengeComment
+			if !tagged && !strings.HasPrefix(record.Name, "_acme-challenge.") {
+				continue
+			}
+			if record.CreatedOn.After(cutoff) {
+				continue
+			}
+
+			stale = append(stale, StaleRecord{
+				ZoneID:    zone.ID,
+				ZoneName:  zone.Name,
+				RecordID:  record.ID,
+				Name:      record.Name,
+				Content:   record.Content,
+				CreatedOn: record.CreatedOn,
+				Tagged:    tagged,
+			})
+		}
+	}
+
+	sort.Slice(stale, func(i, j int) bool {
+		return stale[i].CreatedOn.Before(stale[j].CreatedOn)
+	})
+
+	return stale, nil
+}
+
+// DeleteRecord deletes a DNS record, a record that is already gone is not an error
+func (p *CloudflareProvider) DeleteRecord(zoneID, recordID string) error {
+	return p.deleteRecordContext(p.ctx, zoneID, recordID)
+}
+
+// deleteRecord deletes a challenge record even when the run is being interrupted,
+// so that no record is left behind
+func (p *CloudflareProvider) deleteRecord(zoneID, recordID string) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(p.ctx), cleanupTimeout)
+	defer cancel()
+
+	return p.deleteRecordContext(ctx, zoneID, recordID)
+}
+
+// deleteRecordContext deletes a DNS record within ctx
+func (p *CloudflareProvider) deleteRecordContext(ctx context.Context, zoneID, recordID string) error {
+	err := p.accountFor(zoneID).client.DeleteDNSRecord(ctx, cloudflare.ZoneIdentifier(zoneID), recordID)
+	if err != nil {
+		var notFoundErr *cloudflare.NotFoundError
+		if errors.As(err, &notFoundErr) {
+			return nil
+		}
+		return fmt.Errorf("failed to delete DNS record %s: %w", recordID, err)
+	}
+	return nil
+}
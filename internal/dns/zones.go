@@ -2,40 +2,89 @@ package dns
 
 import (
 	"bufio"
-	"context"
 	"fmt"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
+
+	"github.com/bariiss/flarecert/internal/ui"
 )
 
 // ZoneInfo holds zone information
 type ZoneInfo struct {
-	ID     string
-	Name   string
-	Status string
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Status      string   `json:"status"`
+	Permissions []string `json:"permissions,omitempty"` // Permissions of the API token in the zone
+	Account     string   `json:"account,omitempty"`     // Cloudflare account managing the zone
 }
 
-// ListZones lists all available zones for the user to choose from
+// ListZones lists all available zones for the user to choose from, served from the zone cache when possible
 func (p *CloudflareProvider) ListZones() ([]ZoneInfo, error) {
-	ctx := context.Background()
-	zones, err := p.client.ListZones(ctx)
+	if zones, ok := p.cache.get(); ok {
+		return zones, nil
+	}
+
+	zones, err := p.fetchZones()
 	if err != nil {
-		return nil, fmt.Errorf("failed to list zones: %w", err)
+		return nil, err
 	}
 
+	if err := p.cache.set(zones); err != nil && p.verbose {
+		fmt.Printf("⚠️  Failed to write zone cache: %v\n", err)
+	}
+
+	return zones, nil
+}
+
+// fetchZones lists the zones of every account from the Cloudflare API. A zone visible to
+// several accounts belongs to the account it is pinned to, otherwise to the first one.
+func (p *CloudflareProvider) fetchZones() ([]ZoneInfo, error) {
 	var zoneInfos []ZoneInfo
-	for _, zone := range zones {
-		zoneInfos = append(zoneInfos, ZoneInfo{
-			ID:     zone.ID,
-			Name:   zone.Name,
-			Status: zone.Status,
-		})
+	index := make(map[string]int) // Position in zoneInfos by zone ID
+
+	for _, acc := range p.accounts {
+		zones, err := acc.zoneClient.ListZones(p.ctx)
+		if err != nil {
+			if len(p.accounts) > 1 {
+				return nil, fmt.Errorf("failed to list zones of account %s: %w", acc.name, err)
+			}
+			return nil, fmt.Errorf("failed to list zones: %w", err)
+		}
+
+		for _, zone := range zones {
+			info := ZoneInfo{
+				ID:          zone.ID,
+				Name:        zone.Name,
+				Status:      zone.Status,
+				Permissions: zone.Permissions,
+				Account:     acc.name,
+			}
+
+			if i, seen := index[zone.ID]; seen {
+				if p.pinnedAccount(info) == acc.name {
+					zoneInfos[i] = info
+				}
+				continue
+			}
+
+			index[zone.ID] = len(zoneInfos)
+			zoneInfos = append(zoneInfos, info)
+		}
 	}
 
 	return zoneInfos, nil
 }
 
+// pinnedAccount returns the account a zone is pinned to by name or ID, if any
+func (p *CloudflareProvider) pinnedAccount(zone ZoneInfo) string {
+	if name, ok := p.zoneAccounts[strings.ToLower(zone.ID)]; ok {
+		return name
+	}
+	return p.zoneAccounts[strings.ToLower(zone.Name)]
+}
+
 // SelectZoneInteractive allows user to select a zone interactively
 func (p *CloudflareProvider) SelectZoneInteractive(domain string) (string, error) {
 	zones, err := p.ListZones()
@@ -47,17 +96,20 @@ func (p *CloudflareProvider) SelectZoneInteractive(domain string) (string, error
 		return "", fmt.Errorf("no zones found in your Cloudflare account")
 	}
 
-	// Try to find matching zones for the domain
+	// Try to find matching zones for the domain, on label boundaries and longest suffix first
 	var matchingZones []ZoneInfo
 	for _, zone := range zones {
-		if strings.HasSuffix(domain, zone.Name) || domain == zone.Name {
+		if domainInZone(domain, zone.Name) {
 			matchingZones = append(matchingZones, zone)
 		}
 	}
+	sort.SliceStable(matchingZones, func(i, j int) bool {
+		return len(matchingZones[i].Name) > len(matchingZones[j].Name)
+	})
 
-	// If we have exact matches, use them
+	// If we have matches, the most specific zone is the one holding the domain
 	if len(matchingZones) > 0 {
-		zones = matchingZones
+		zones = matchingZones[:1]
 	}
 
 	if len(zones) == 1 {
@@ -101,13 +153,27 @@ func (p *CloudflareProvider) SelectZoneInteractive(domain string) (string, error
 	return selectedZone.ID, nil
 }
 
-// GetZoneIDForDomain gets the zone ID for a domain, with interactive selection if needed
+// SetZoneOverrides sets a zone (name or ID) used for every domain and a mapping of
+// domain suffixes to zone IDs, both taking precedence over automatic detection
+func (p *CloudflareProvider) SetZoneOverrides(zone string, zoneMap map[string]string) {
+	p.zone = zone
+	p.zoneMap = zoneMap
+}
+
+// GetZoneIDForDomain gets the zone ID holding the challenge record of a domain, with
+// interactive selection if needed
 func (p *CloudflareProvider) GetZoneIDForDomain(domain string) (string, error) {
-	// First try automatic detection
-	zoneID, err := p.getZoneIDAutomatic(domain)
+	// First try the configured zones and automatic detection
+	zoneID, err := p.challengeZoneID(domain)
 	if err == nil {
 		return zoneID, nil
 	}
+	domain = p.challengeZoneDomain(domain)
+
+	// Prompting without a terminal would block forever, e.g. in CI or containers
+	if !ui.IsInteractive() {
+		return "", fmt.Errorf("%w; stdin is not a terminal, set the zone with --zone or CLOUDFLARE_ZONE_MAP", err)
+	}
 
 	if p.verbose {
 		fmt.Printf("⚠️  Automatic zone detection failed: %v\n", err)
@@ -115,34 +181,142 @@ func (p *CloudflareProvider) GetZoneIDForDomain(domain string) (string, error) {
 	}
 
 	// Fall back to interactive selection
-	return p.SelectZoneInteractive(domain)
+	zoneID, err = p.SelectZoneInteractive(domain)
+	if err != nil {
+		return "", err
+	}
+
+	p.cache.remember(domain, zoneID)
+	return zoneID, nil
+}
+
+// challengeZoneID resolves the zone of the challenge record of a domain. --zone only applies
+// to the domain's own zone, a delegated record lives in the zone of the CNAME target.
+func (p *CloudflareProvider) challengeZoneID(domain string) (string, error) {
+	zoneDomain := p.challengeZoneDomain(domain)
+	if zoneDomain == domain {
+		return p.lookupZoneID(domain)
+	}
+
+	if zoneID, ok := p.mappedZoneID(zoneDomain); ok {
+		return zoneID, nil
+	}
+	return p.getZoneIDAutomatic(zoneDomain)
+}
+
+// lookupZoneID resolves the zone of a domain from --zone, the zone map or automatic detection
+func (p *CloudflareProvider) lookupZoneID(domain string) (string, error) {
+	if p.zone != "" {
+		return p.resolveZone(p.zone)
+	}
+
+	if zoneID, ok := p.mappedZoneID(domain); ok {
+		return zoneID, nil
+	}
+
+	return p.getZoneIDAutomatic(domain)
+}
+
+// resolveZone returns the ID of a zone given by name or ID
+func (p *CloudflareProvider) resolveZone(zone string) (string, error) {
+	zones, err := p.ListZones()
+	if err != nil {
+		return "", err
+	}
+
+	for _, z := range zones {
+		if z.ID == zone || strings.EqualFold(z.Name, zone) {
+			return z.ID, nil
+		}
+	}
+
+	return "", fmt.Errorf("zone %s not found in your Cloudflare account", zone)
+}
+
+// mappedZoneID returns the zone ID of the longest configured suffix the domain belongs to
+func (p *CloudflareProvider) mappedZoneID(domain string) (string, bool) {
+	var bestSuffix, bestZoneID string
+	for suffix, zoneID := range p.zoneMap {
+		if domainInZone(domain, suffix) && len(suffix) > len(bestSuffix) {
+			bestSuffix, bestZoneID = suffix, zoneID
+		}
+	}
+
+	if bestSuffix == "" {
+		return "", false
+	}
+
+	if p.verbose {
+		fmt.Printf("🎯 Using mapped zone for %s: %s (%s)\n", domain, bestSuffix, bestZoneID)
+	}
+	return bestZoneID, true
+}
+
+// domainInZone reports whether domain is the zone apex or a name below it
+func domainInZone(domain, zone string) bool {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	zone = strings.ToLower(strings.TrimSuffix(zone, "."))
+	return domain == zone || strings.HasSuffix(domain, "."+zone)
 }
 
 // getZoneIDAutomatic tries to automatically detect the zone for a domain
 func (p *CloudflareProvider) getZoneIDAutomatic(domain string) (string, error) {
+	if zoneID, ok := p.cache.lookup(domain); ok {
+		return zoneID, nil
+	}
+
 	// Remove any subdomain parts to find the zone
-	parts := strings.Split(domain, ".")
+	parts := strings.Split(strings.ToLower(domain), ".")
 	if len(parts) < 2 {
 		return "", fmt.Errorf("invalid domain: %s", domain)
 	}
 
-	// Try different combinations to find the zone
-	ctx := context.Background()
-	for i := 0; i < len(parts)-1; i++ {
-		candidateZone := strings.Join(parts[i:], ".")
+	zones, err := p.ListZones()
+	if err != nil {
+		return "", err
+	}
 
-		zones, err := p.client.ListZones(ctx, candidateZone)
-		if err != nil {
-			continue
+	zone, ok := findZone(zones, parts)
+	if !ok {
+		// The cached zone list may predate the zone, reload it once
+		if zones, err = p.RefreshZones(); err != nil {
+			return "", err
 		}
+		if zone, ok = findZone(zones, parts); !ok {
+			return "", fmt.Errorf("no zone found for domain: %s", domain)
+		}
+	}
 
-		if len(zones) > 0 {
-			if p.verbose {
-				fmt.Printf("🎯 Found zone automatically: %s (%s)\n", zones[0].Name, zones[0].ID)
-			}
-			return zones[0].ID, nil
+	if p.verbose {
+		fmt.Printf("🎯 Found zone automatically: %s (%s)\n", zone.Name, zone.ID)
+	}
+	p.cache.remember(domain, zone.ID)
+	return zone.ID, nil
+}
+
+// findZone returns the zone for the domain labels, trying the most specific candidate first
+func findZone(zones []ZoneInfo, parts []string) (ZoneInfo, bool) {
+	zonesByName := make(map[string]ZoneInfo, len(zones))
+	for _, zone := range zones {
+		zonesByName[strings.ToLower(zone.Name)] = zone
+	}
+
+	for i := 0; i < len(parts)-1; i++ {
+		if zone, ok := zonesByName[strings.Join(parts[i:], ".")]; ok {
+			return zone, true
 		}
 	}
 
-	return "", fmt.Errorf("no zone found for domain: %s", domain)
+	return ZoneInfo{}, false
+}
+
+// ValidateDomains checks that the challenge record of every domain belongs to a Cloudflare zone
+// accessible with the API token, which is the delegated zone for aliased domains
+func (p *CloudflareProvider) ValidateDomains(domains []string) error {
+	for _, domain := range domains {
+		if _, err := p.challengeZoneID(strings.TrimPrefix(domain, "*.")); err != nil {
+			return fmt.Errorf("domain %s is not in any accessible Cloudflare zone: %w", domain, err)
+		}
+	}
+	return nil
 }
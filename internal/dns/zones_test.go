@@ -0,0 +1,58 @@
+package dns
+
+import "testing"
+
+func TestDomainInZone(t *testing.T) {
+	tests := []struct {
+		domain string
+		zone   string
+		want   bool
+	}{
+		{"example.com", "example.com", true},
+		{"www.example.com", "example.com", true},
+		{"a.b.example.com", "example.com", true},
+		{"WWW.Example.COM", "example.com", true},
+		{"www.example.com.", "example.com.", true},
+		{"example.com", "www.example.com", false},
+		{"badexample.com", "example.com", false},
+		{"example.com.evil.net", "example.com", false},
+		{"example.org", "example.com", false},
+	}
+
+	for _, tt := range tests {
+		if got := domainInZone(tt.domain, tt.zone); got != tt.want {
+			t.Errorf("domainInZone(%q, %q) = %v, want %v", tt.domain, tt.zone, got, tt.want)
+		}
+	}
+}
+
+func TestMappedZoneID(t *testing.T) {
+	p := &CloudflareProvider{
+		zoneMap: map[string]string{
+			"example.com":          "zone-example",
+			"internal.example.com": "zone-internal",
+			"example.org":          "zone-org",
+		},
+	}
+
+	tests := []struct {
+		domain string
+		want   string
+		ok     bool
+	}{
+		{"example.com", "zone-example", true},
+		{"www.example.com", "zone-example", true},
+		{"internal.example.com", "zone-internal", true},
+		{"app.internal.example.com", "zone-internal", true},
+		{"notinternal.example.com", "zone-example", true},
+		{"badexample.com", "", false},
+		{"example.net", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := p.mappedZoneID(tt.domain)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("mappedZoneID(%q) = %q, %v, want %q, %v", tt.domain, got, ok, tt.want, tt.ok)
+		}
+	}
+}
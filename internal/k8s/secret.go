@@ -45,8 +45,8 @@ func (sg *SecretGenerator) CreateSecret(paths *utils.CertificatePaths, primaryDo
 	keyB64 := base64.StdEncoding.EncodeToString(keyData)
 	fullchainB64 := base64.StdEncoding.EncodeToString(fullchainData)
 
-	// Generate secret name (safe for Kubernetes)
-	secretName := GenerateSecretName(primaryDomain)
+	// Generate secret name (safe for Kubernetes), one secret per RSA/ECDSA variant
+	secretName := GenerateVariantSecretName(primaryDomain, paths.Variant)
 
 	// Create YAML content
 	yamlContent := sg.generateYAMLContent(secretName, primaryDomain, domains, certB64, keyB64, fullchainB64)
@@ -95,3 +95,12 @@ func GenerateSecretName(domain string) string {
 	// Add suffix to avoid conflicts
 	return fmt.Sprintf("%s-tls", name)
 }
+
+// GenerateVariantSecretName generates a Kubernetes-safe secret name for a key type variant
+func GenerateVariantSecretName(domain, variant string) string {
+	name := GenerateSecretName(domain)
+	if variant == "" {
+		return name
+	}
+	return strings.TrimSuffix(name, "-tls") + "-" + variant + "-tls"
+}
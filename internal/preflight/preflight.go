@@ -0,0 +1,430 @@
+package preflight
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bariiss/flarecert/internal/acme"
+	"github.com/bariiss/flarecert/internal/config"
+	"github.com/bariiss/flarecert/internal/dns"
+)
+
+// tokenURL is where Cloudflare API tokens are managed
+const tokenURL = "https://dash.cloudflare.com/profile/api-tokens"
+
+// Clock offsets from the ACME server above these limits are reported
+const (
+	clockSkewWarn = 30 * time.Second
+	clockSkewFail = 5 * time.Minute
+)
+
+// tokenExpiryWarn is how long before expiry an API token is reported
+const tokenExpiryWarn = 7 * 24 * time.Hour
+
+// Status is the outcome of a check
+type Status int
+
+const (
+	StatusOK Status = iota
+	StatusWarn
+	StatusFail
+)
+
+// Result is the outcome of a single check
+type Result struct {
+	Name   string
+	Status Status
+	Detail string
+	Fix    string // What to do about a warning or failure
+}
+
+// Report holds the results of all checks
+type Report struct {
+	Results []Result
+}
+
+// Options selects what is checked
+type Options struct {
+	Config    *config.Config
+	Servers   []string // ACME directories to check, the configured CAs when empty
+	Domains   []string // Domains whose zones must be editable, every zone when empty
+	WriteTest bool     // Prove edit access by creating and deleting a probe record
+	CAA       bool     // Check that CAA records allow the configured CAs to issue for the domains
+	Verbose   bool
+}
+
+// Run performs the checks. A failed check does not stop the remaining ones.
+func Run(ctx context.Context, opts Options) *Report {
+	report := &Report{}
+	cfg := opts.Config
+
+	report.checkCertDir(cfg.CertDir)
+
+	servers := opts.Servers
+	if len(servers) == 0 {
+		for _, ca := range cfg.IssuanceCAs() {
+			servers = append(servers, ca.Server)
+		}
+	}
+	for _, server := range servers {
+		report.checkACME(server)
+	}
+
+	if opts.CAA {
+		report.checkCAA(ctx, cfg, opts.Domains)
+	}
+
+	provider, err := dns.NewProviderFromConfig(ctx, cfg, cfg.CertDir, opts.Verbose)
+	if err != nil {
+		report.add(Result{
+			Name:   "Cloudflare credentials",
+			Status: StatusFail,
+			Detail: err.Error(),
+			Fix:    credentialsFix(cfg),
+		})
+		return report
+	}
+
+	accounts := provider.Accounts()
+	for _, account := range accounts {
+		// Name the account only when there are several
+		label := ""
+		if len(accounts) > 1 {
+			label = fmt.Sprintf(" (account %s)", account.Name)
+		}
+
+		if account.Mode == config.AuthGlobalKey {
+			report.add(Result{
+				Name:   "Cloudflare Global API Key" + label,
+				Status: StatusOK,
+				Detail: "valid (full account access, a scoped API token is safer)",
+			})
+		}
+		for _, token := range account.Tokens {
+			report.checkToken(token, account.Name, label)
+		}
+	}
+	report.checkZones(provider, opts)
+
+	return report
+}
+
+// add records a result
+func (r *Report) add(result Result) {
+	r.Results = append(r.Results, result)
+}
+
+// Failed reports whether any check failed
+func (r *Report) Failed() bool {
+	for _, result := range r.Results {
+		if result.Status == StatusFail {
+			return true
+		}
+	}
+	return false
+}
+
+// Print prints every result, or only warnings and failures when quiet is set
+func (r *Report) Print(quiet bool) {
+	for _, result := range r.Results {
+		icon := "✅"
+		switch result.Status {
+		case StatusWarn:
+			icon = "⚠️ "
+		case StatusFail:
+			icon = "❌"
+		default:
+			if quiet {
+				continue
+			}
+		}
+
+		fmt.Printf("%s %s: %s\n", icon, result.Name, result.Detail)
+		if result.Fix != "" && result.Status != StatusOK {
+			fmt.Printf("   💡 %s\n", result.Fix)
+		}
+	}
+}
+
+// Err summarizes the failed checks, nil when everything passed
+func (r *Report) Err() error {
+	var failed []string
+	for _, result := range r.Results {
+		if result.Status == StatusFail {
+			failed = append(failed, result.Name)
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return fmt.Errorf("preflight failed: %s", strings.Join(failed, ", "))
+}
+
+// checkCertDir makes sure certificates and accounts can be written
+func (r *Report) checkCertDir(certDir string) {
+	name := "Certificate directory"
+	fix := fmt.Sprintf("Make %s writable for this user or choose another one with --cert-dir", certDir)
+
+	if err := os.MkdirAll(certDir, 0755); err != nil {
+		r.add(Result{Name: name, Status: StatusFail, Detail: err.Error(), Fix: fix})
+		return
+	}
+
+	probe, err := os.CreateTemp(certDir, ".flarecert-doctor-*")
+	if err != nil {
+		r.add(Result{Name: name, Status: StatusFail, Detail: fmt.Sprintf("%s is not writable: %v", certDir, err), Fix: fix})
+		return
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+
+	abs, _ := filepath.Abs(certDir)
+	r.add(Result{Name: name, Status: StatusOK, Detail: fmt.Sprintf("%s is writable", abs)})
+}
+
+// checkACME makes sure the ACME directory is reachable and the local clock is correct
+func (r *Report) checkACME(server string) {
+	name := fmt.Sprintf("ACME directory %s", server)
+
+	skew, err := acme.CheckDirectory(server)
+	if err != nil {
+		r.add(Result{
+			Name:   name,
+			Status: StatusFail,
+			Detail: err.Error(),
+			Fix:    "Check network access and proxy settings, or select a reachable CA with ACME_CA / --ca",
+		})
+		return
+	}
+	r.add(Result{Name: name, Status: StatusOK, Detail: "reachable"})
+
+	result := Result{Name: "Clock", Status: StatusOK, Detail: fmt.Sprintf("%s off from %s", skew.Abs(), server)}
+	switch abs := skew.Abs(); {
+	case abs > clockSkewFail:
+		result.Status = StatusFail
+	case abs > clockSkewWarn:
+		result.Status = StatusWarn
+	}
+	if result.Status != StatusOK {
+		result.Fix = "Synchronize the system clock, e.g. enable NTP with 'timedatectl set-ntp true'"
+	}
+	r.add(result)
+}
+
+// checkCAA makes sure the CAA records of every domain allow the configured CA to issue.
+// Fallback CAs the records refuse are only reported as warnings.
+func (r *Report) checkCAA(ctx context.Context, cfg *config.Config, domains []string) {
+	sets := make(map[string]dns.CAASet) // Looked up once per name for all CAs
+	cas := cfg.IssuanceCAs()
+
+	for i, ca := range cas {
+		// Name the CA only when fallbacks are configured
+		label := ""
+		if len(cas) > 1 {
+			label = fmt.Sprintf(" (%s)", ca.Server)
+		}
+
+		identities := cfg.CAAIdentitiesFor(ca.Server)
+		if len(identities) == 0 {
+			r.add(Result{
+				Name:   "CAA" + label,
+				Status: StatusWarn,
+				Detail: fmt.Sprintf("CAA identity of %s is unknown, records are not checked", ca.Server),
+				Fix:    "Set ACME_CAA_IDENTITY to the issuer domain the CA documents for CAA records",
+			})
+			continue
+		}
+
+		refused := StatusFail
+		if i > 0 {
+			refused = StatusWarn
+		}
+		accountURI := acme.StoredAccountURI(cfg.CertDir, ca.Server, cfg.ACMEEmail)
+
+		for _, domain := range domains {
+			name := fmt.Sprintf("CAA %s%s", domain, label)
+			base := strings.ToLower(strings.TrimPrefix(domain, "*."))
+			wildcard := base != strings.ToLower(domain)
+
+			set, ok := sets[base]
+			if !ok {
+				var err error
+				if set, err = dns.LookupCAA(ctx, base, cfg.DNSResolvers); err != nil {
+					r.add(Result{
+						Name:   name,
+						Status: StatusWarn,
+						Detail: err.Error(),
+						Fix:    "CAs refuse to issue while CAA lookups fail, check the nameservers and DNSSEC of the zone",
+					})
+					continue
+				}
+				sets[base] = set
+			}
+
+			if err := set.Permits(identities, accountURI, wildcard); err != nil {
+				command := fmt.Sprintf("flarecert dns caa --domain %s", set.Owner)
+				if wildcard {
+					command += " --wildcard"
+				}
+				r.add(Result{
+					Name:   name,
+					Status: refused,
+					Detail: err.Error(),
+					Fix:    fmt.Sprintf("Authorize the CA with '%s', or choose a CA the records allow", command),
+				})
+				continue
+			}
+
+			detail := "no CAA records, any CA may issue"
+			if len(set.Records) > 0 {
+				detail = fmt.Sprintf("%s allowed by the CAA records at %s", identities[0], set.Owner)
+			}
+			r.add(Result{Name: name, Status: StatusOK, Detail: detail})
+		}
+	}
+}
+
+// credentialsFix explains how to set up the configured credentials
+func credentialsFix(cfg *config.Config) string {
+	// The error names the failing account, whose variables carry its prefix
+	if len(cfg.CloudflareAccounts) > 0 {
+		return fmt.Sprintf("Check the CLOUDFLARE_<NAME>_ credentials of the account named above, tokens need Zone:Read and DNS:Edit (%s)", tokenURL)
+	}
+
+	switch cfg.CloudflareAuth {
+	case config.AuthGlobalKey:
+		return "Check CLOUDFLARE_API_KEY (Global API Key) and CLOUDFLARE_EMAIL of the account owning the zones"
+	case config.AuthSplit:
+		return fmt.Sprintf("Set CLOUDFLARE_ZONE_TOKEN to a token with Zone:Read and CLOUDFLARE_DNS_TOKEN to a token with DNS:Edit (%s)", tokenURL)
+	default:
+		return fmt.Sprintf("Set CLOUDFLARE_API_TOKEN to a token with Zone:Read and DNS:Edit permissions (%s)", tokenURL)
+	}
+}
+
+// tokenEnv returns the variable holding a token of an account
+func tokenEnv(token dns.TokenInfo, account string) string {
+	prefix := config.AccountEnvPrefix(account)
+
+	switch token.Name {
+	case "zone token":
+		return prefix + "ZONE_TOKEN"
+	case "DNS token":
+		return prefix + "DNS_TOKEN"
+	default:
+		return prefix + "API_TOKEN"
+	}
+}
+
+// checkToken reports the status and expiry of a Cloudflare API token
+func (r *Report) checkToken(token dns.TokenInfo, account, label string) {
+	name := "Cloudflare " + token.Name + label
+	fix := fmt.Sprintf("Roll or recreate the token at %s and update %s", tokenURL, tokenEnv(token, account))
+
+	if token.Status != "" && token.Status != "active" {
+		r.add(Result{Name: name, Status: StatusFail, Detail: fmt.Sprintf("token is %s", token.Status), Fix: fix})
+		return
+	}
+
+	if token.ExpiresOn.IsZero() {
+		r.add(Result{Name: name, Status: StatusOK, Detail: "active, does not expire"})
+		return
+	}
+
+	remaining := time.Until(token.ExpiresOn)
+	detail := fmt.Sprintf("active, expires %s", token.ExpiresOn.Local().Format("2006-01-02 15:04"))
+	switch {
+	case remaining <= 0:
+		r.add(Result{Name: name, Status: StatusFail, Detail: "token has expired", Fix: fix})
+	case remaining < tokenExpiryWarn:
+		r.add(Result{Name: name, Status: StatusWarn, Detail: detail, Fix: fix})
+	default:
+		r.add(Result{Name: name, Status: StatusOK, Detail: detail})
+	}
+}
+
+// checkZones reports which zones the token can read and edit. With domains only their
+// zones are checked, and each must be editable.
+func (r *Report) checkZones(provider *dns.CloudflareProvider, opts Options) {
+	var zones []dns.ZoneInfo
+
+	if len(opts.Domains) == 0 {
+		// Fresh zones carry the token permissions
+		var err error
+		zones, err = provider.RefreshZones()
+		if err != nil {
+			r.add(Result{
+				Name:   "Cloudflare zones",
+				Status: StatusFail,
+				Detail: err.Error(),
+				Fix:    "Grant the token Zone:Read on the zones it should manage",
+			})
+			return
+		}
+		if len(zones) == 0 {
+			r.add(Result{
+				Name:   "Cloudflare zones",
+				Status: StatusFail,
+				Detail: "the token cannot read any zone",
+				Fix:    "Grant the token Zone:Read under Zone Resources for the zones it should manage",
+			})
+			return
+		}
+	} else {
+		seen := make(map[string]bool)
+		for _, domain := range opts.Domains {
+			zone, err := provider.ZoneForDomain(domain)
+			if err != nil {
+				r.add(Result{
+					Name:   fmt.Sprintf("Zone for %s", domain),
+					Status: StatusFail,
+					Detail: err.Error(),
+					Fix:    "Add the zone to Cloudflare and to the token's Zone Resources, or set --zone / CLOUDFLARE_ZONE_MAP",
+				})
+				continue
+			}
+			if !seen[zone.ID] {
+				seen[zone.ID] = true
+				zones = append(zones, zone)
+			}
+		}
+	}
+
+	for _, zone := range zones {
+		access := provider.CheckZoneAccess(zone, opts.WriteTest)
+		name := fmt.Sprintf("Zone %s", zone.Name)
+
+		switch {
+		case !access.Read:
+			r.add(Result{
+				Name:   name,
+				Status: StatusFail,
+				Detail: access.Err.Error(),
+				Fix:    "Grant the token Zone:Read and DNS:Read on this zone",
+			})
+		case access.EditKnown && !access.Edit:
+			detail := "read only"
+			if access.Err != nil {
+				detail = access.Err.Error()
+			}
+			r.add(Result{
+				Name:   name,
+				Status: StatusFail,
+				Detail: detail,
+				Fix:    "Grant the token DNS:Edit on this zone",
+			})
+		case !access.EditKnown:
+			r.add(Result{
+				Name:   name,
+				Status: StatusWarn,
+				Detail: "readable, edit permission not reported by Cloudflare",
+				Fix:    "Run 'flarecert doctor --write-test' to verify DNS:Edit with a probe record",
+			})
+		default:
+			r.add(Result{Name: name, Status: StatusOK, Detail: "read and edit"})
+		}
+	}
+}
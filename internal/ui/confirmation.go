@@ -17,6 +17,15 @@ const (
 	ConfirmCancel
 )
 
+// IsInteractive reports whether stdin is a terminal the user can answer prompts on
+func IsInteractive() bool {
+	info, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
 // AskUserConfirmation prompts the user for yes/no confirmation
 func AskUserConfirmation(message string) bool {
 	reader := bufio.NewReader(os.Stdin)
@@ -104,38 +104,69 @@ func CreateCertificateStructureForDomains(baseDir string, domains []string) erro
 
 // GetCertificatePaths returns all the file paths for a certificate
 func GetCertificatePaths(baseDir, domain string) CertificatePaths {
-	certDir := GetCertificateDir(baseDir, domain)
-	currentDir := filepath.Join(certDir, "current")
-
-	return CertificatePaths{
-		CertDir:       certDir,
-		CurrentDir:    currentDir,
-		ArchiveDir:    filepath.Join(certDir, "archive"),
-		LogsDir:       filepath.Join(certDir, "logs"),
-		CertFile:      filepath.Join(currentDir, "cert.pem"),
-		KeyFile:       filepath.Join(currentDir, "privkey.pem"),
-		ChainFile:     filepath.Join(currentDir, "chain.pem"),
-		FullchainFile: filepath.Join(currentDir, "fullchain.pem"),
-		InfoFile:      filepath.Join(currentDir, "cert.json"),
-	}
+	return GetCertificatePathsForDir(GetCertificateDir(baseDir, domain), "")
 }
 
 // GetCertificatePathsForDomains returns all the file paths for a certificate based on domain list
 func GetCertificatePathsForDomains(baseDir string, domains []string) CertificatePaths {
-	certDir := GetCertificateDirForDomains(baseDir, domains)
-	currentDir := filepath.Join(certDir, "current")
+	return GetCertificatePathsForDir(GetCertificateDirForDomains(baseDir, domains), "")
+}
+
+// GetCertificatePathsForVariant returns the file paths of one key type variant of a certificate
+// issued as both RSA and ECDSA. An empty variant returns the regular certificate paths.
+func GetCertificatePathsForVariant(baseDir string, domains []string, variant string) CertificatePaths {
+	return GetCertificatePathsForDir(GetCertificateDirForDomains(baseDir, domains), variant)
+}
+
+// GetCertificatePathsForDir returns all the file paths for the certificate stored in certDir.
+// Variants live in their own current/<variant> and archive/<variant> subdirectories.
+func GetCertificatePathsForDir(certDir, variant string) CertificatePaths {
+	currentDir := filepath.Join(certDir, "current", variant)
 
 	return CertificatePaths{
 		CertDir:       certDir,
 		CurrentDir:    currentDir,
-		ArchiveDir:    filepath.Join(certDir, "archive"),
+		ArchiveDir:    filepath.Join(certDir, "archive", variant),
 		LogsDir:       filepath.Join(certDir, "logs"),
 		CertFile:      filepath.Join(currentDir, "cert.pem"),
 		KeyFile:       filepath.Join(currentDir, "privkey.pem"),
 		ChainFile:     filepath.Join(currentDir, "chain.pem"),
 		FullchainFile: filepath.Join(currentDir, "fullchain.pem"),
 		InfoFile:      filepath.Join(currentDir, "cert.json"),
+		CSRFile:       filepath.Join(currentDir, "request.csr"),
+		Variant:       variant,
+	}
+}
+
+// CertificateVariants are the key type variants stored side by side for dual RSA and ECDSA certificates
+var CertificateVariants = []string{"rsa", "ecdsa"}
+
+// KeyVariant returns the variant a key type is stored under when issuing both RSA and ECDSA certificates
+func KeyVariant(keyType string) string {
+	if strings.HasPrefix(keyType, "ec") {
+		return "ecdsa"
+	}
+	return "rsa"
+}
+
+// FindCertificateVariants returns the variants with a certificate in certDir.
+// The empty variant stands for a regular single certificate in current/.
+func FindCertificateVariants(certDir string) []string {
+	var variants []string
+	for _, variant := range append([]string{""}, CertificateVariants...) {
+		if _, err := os.Stat(GetCertificatePathsForDir(certDir, variant).CertFile); err == nil {
+			variants = append(variants, variant)
+		}
+	}
+	return variants
+}
+
+// VariantLabel returns a display name for a certificate directory and variant
+func VariantLabel(name, variant string) string {
+	if variant == "" {
+		return name
 	}
+	return fmt.Sprintf("%s (%s)", name, variant)
 }
 
 // CertificatePaths holds all file paths for a certificate
@@ -149,6 +180,8 @@ type CertificatePaths struct {
 	ChainFile     string
 	FullchainFile string
 	InfoFile      string
+	CSRFile       string
+	Variant       string
 }
 
 // ArchiveOldCertificate moves current certificate to archive before creating new one
@@ -169,6 +202,7 @@ func ArchiveOldCertificate(paths CertificatePaths) error {
 		paths.ChainFile:     filepath.Join(paths.ArchiveDir, archivePrefix+"-chain.pem"),
 		paths.FullchainFile: filepath.Join(paths.ArchiveDir, archivePrefix+"-fullchain.pem"),
 		paths.InfoFile:      filepath.Join(paths.ArchiveDir, archivePrefix+"-cert.json"),
+		paths.CSRFile:       filepath.Join(paths.ArchiveDir, archivePrefix+"-request.csr"),
 	}
 
 	for source, dest := range files {
@@ -9,18 +9,39 @@ import (
 
 // CertificateMetadata holds metadata about a certificate
 type CertificateMetadata struct {
-	Domain       string    `json:"domain"`
-	Domains      []string  `json:"domains"`
-	IsWildcard   bool      `json:"is_wildcard"`
-	KeyType      string    `json:"key_type"`
-	CreatedAt    time.Time `json:"created_at"`
-	ExpiresAt    time.Time `json:"expires_at"`
-	Issuer       string    `json:"issuer"`
-	SerialNumber string    `json:"serial_number"`
-	Fingerprint  string    `json:"fingerprint"`
-	ACMEServer   string    `json:"acme_server"`
-	Version      string    `json:"version"`
-	RenewalCount int       `json:"renewal_count"`
+	Domain           string         `json:"domain"`
+	Domains          []string       `json:"domains"`
+	IsWildcard       bool           `json:"is_wildcard"`
+	KeyType          string         `json:"key_type"`
+	CreatedAt        time.Time      `json:"created_at"`
+	ExpiresAt        time.Time      `json:"expires_at"`
+	Issuer           string         `json:"issuer"`
+	SerialNumber     string         `json:"serial_number"`
+	Fingerprint      string         `json:"fingerprint"`
+	ACMEServer       string         `json:"acme_server"`
+	Version          string         `json:"version"`
+	RenewalCount     int            `json:"renewal_count"`
+	FromCSR          bool           `json:"from_csr,omitempty"`
+	PreferredChain   string         `json:"preferred_chain,omitempty"`
+	Profile          string         `json:"profile,omitempty"`
+	MustStaple       bool           `json:"must_staple,omitempty"`
+	Zone             string         `json:"zone,omitempty"`
+	ReuseKey         bool           `json:"reuse_key,omitempty"`
+	MaxKeyAgeDays    int            `json:"max_key_age_days,omitempty"`
+	KeyCreatedAt     *time.Time     `json:"key_created_at,omitempty"`
+	Revoked          bool           `json:"revoked,omitempty"`
+	RevokedAt        *time.Time     `json:"revoked_at,omitempty"`
+	RevocationReason string         `json:"revocation_reason,omitempty"`
+	RenewalWindow    *RenewalWindow `json:"renewal_window,omitempty"`
+}
+
+// RenewalWindow caches the CA's suggested renewal window until the CA asks to be polled again
+type RenewalWindow struct {
+	CertID         string    `json:"cert_id"`
+	Start          time.Time `json:"start"`
+	End            time.Time `json:"end"`
+	ExplanationURL string    `json:"explanation_url,omitempty"`
+	NextCheck      time.Time `json:"next_check"`
 }
 
 // SaveCertificateMetadata saves certificate metadata to JSON file
@@ -53,6 +74,21 @@ func LoadCertificateMetadata(filePath string) (CertificateMetadata, error) {
 	return metadata, nil
 }
 
+// MarkCertificateRevoked records a revocation in the metadata file
+func MarkCertificateRevoked(filePath string, reason string) error {
+	metadata, err := LoadCertificateMetadata(filePath)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	metadata.Revoked = true
+	metadata.RevokedAt = &now
+	metadata.RevocationReason = reason
+
+	return SaveCertificateMetadata(filePath, metadata)
+}
+
 // UpdateRenewalCount increments the renewal count in metadata
 func UpdateRenewalCount(filePath string) error {
 	metadata, err := LoadCertificateMetadata(filePath)
@@ -64,3 +100,15 @@ func UpdateRenewalCount(filePath string) error {
 	metadata.RenewalCount++
 	return SaveCertificateMetadata(filePath, metadata)
 }
+
+// SaveRenewalWindow records the CA's suggested renewal window in the metadata file
+func SaveRenewalWindow(filePath string, window RenewalWindow) error {
+	metadata, err := LoadCertificateMetadata(filePath)
+	if err != nil {
+		return err
+	}
+
+	metadata.RenewalWindow = &window
+
+	return SaveCertificateMetadata(filePath, metadata)
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"log"
 	"os"
 
@@ -16,6 +17,9 @@ func main() {
 	// Execute the root command
 	if err := cmd.Execute(); err != nil {
 		log.Printf("Error: %v", err)
+		if errors.Is(err, cmd.ErrInterrupted) {
+			os.Exit(cmd.ExitInterrupted)
+		}
 		os.Exit(1)
 	}
 }